@@ -0,0 +1,128 @@
+package action
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+type Type int
+
+const (
+	IncreaseCapacity Type = iota
+	DrainInstance
+	RemoveInstance
+	SetCapacity
+	StopTask
+)
+
+// Action is a mutating call the manager made, or planned to make when
+// running in dry run mode, on behalf of an alert
+type Action struct {
+	Type                 Type
+	ClusterArn           string
+	ContainerInstanceArn string
+	Reason               string
+	Alert                alert.Alert
+	DryRun               bool
+	Error                string
+	ActionDate           time.Time
+}
+
+func (t Type) String() string {
+	switch t {
+	case IncreaseCapacity:
+		return "IncreaseCapacity"
+	case DrainInstance:
+		return "DrainInstance"
+	case RemoveInstance:
+		return "RemoveInstance"
+	case SetCapacity:
+		return "SetCapacity"
+	case StopTask:
+		return "StopTask"
+	}
+	return "?"
+}
+
+// MarshalText lets the status API report action types by name
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("Cluster: %s Action: %s DryRun: %t Instance: %s Reason: %s", a.ClusterArn, a.Type, a.DryRun, a.ContainerInstanceArn, a.Reason)
+}
+
+// NewAction returns an action taken for the given alert against the alert's
+// cluster
+func NewAction(actionType Type, alertItem *alert.Alert, containerInstanceArn string, reason string, dryRun bool) *Action {
+	return &Action{
+		Type:                 actionType,
+		ClusterArn:           alertItem.ClusterArn,
+		ContainerInstanceArn: containerInstanceArn,
+		Reason:               reason,
+		Alert:                *alertItem,
+		DryRun:               dryRun,
+		ActionDate:           time.Now(),
+	}
+}
+
+// Recorder keeps the most recent actions in memory
+type Recorder struct {
+	mutex   sync.RWMutex
+	actions []*Action
+	size    int
+}
+
+// NewRecorder returns a Recorder holding at most size actions
+func NewRecorder(size int) *Recorder {
+	return &Recorder{
+		actions: make([]*Action, 0, size),
+		size:    size,
+	}
+}
+
+// Record adds an action, dropping the oldest one once the recorder is full
+func (r *Recorder) Record(a *Action) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size <= 0 {
+		return
+	}
+	if len(r.actions) == r.size {
+		copy(r.actions, r.actions[1:])
+		r.actions = r.actions[:len(r.actions)-1]
+	}
+	r.actions = append(r.actions, a)
+}
+
+// Recent returns up to n actions, newest first
+func (r *Recorder) Recent(n int) []*Action {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if n <= 0 || n > len(r.actions) {
+		n = len(r.actions)
+	}
+	response := make([]*Action, 0, n)
+	for i := len(r.actions) - 1; i >= len(r.actions)-n; i-- {
+		response = append(response, r.actions[i])
+	}
+	return response
+}
+
+var defaultRecorder = NewRecorder(500)
+
+// Record adds an action to the default recorder
+func Record(a *Action) {
+	defaultRecorder.Record(a)
+}
+
+// Recent returns up to n actions from the default recorder, newest first
+func Recent(n int) []*Action {
+	return defaultRecorder.Recent(n)
+}
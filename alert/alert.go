@@ -2,6 +2,7 @@ package alert
 
 import (
 	"sort"
+	"strings"
 	"time"
 	"fmt"
 )
@@ -12,6 +13,9 @@ const (
 	ScaleUp   Type = iota
 	ScaleDown
 	Retire
+	// Escalation asks for an operator, e.g. instances still running tasks
+	// long after they were drained
+	Escalation
 )
 
 type Status int
@@ -21,6 +25,11 @@ const (
 	Pending
 	InProgress
 	Completed
+	// Failed alerts stopped on an action error retrying will not fix
+	Failed
+	// Cancelled alerts were dropped before finishing, e.g. once the
+	// condition that raised them cleared
+	Cancelled
 )
 
 type Trigger int
@@ -30,55 +39,141 @@ const (
 	Schedule
 	Service
 	Instance
+	// Image retires instances running an outdated AMI, in batches
+	Image
+	// DrainTimeout escalates a drain that outlasted DrainTimeoutSeconds
+	DrainTimeout
 )
 
+// Step is the next step of a Retire alert, persisted with the alert so a
+// restarted manager resumes the retirement where it stopped
+type Step int
+
+const (
+	// Surge launches replacements for the retiring instances
+	Surge Step = iota
+	// AwaitReplacement waits for the replacements to register with ECS
+	AwaitReplacement
+	// Drain drains the retiring instances
+	Drain
+	// Remove waits for the tasks to leave the retiring instances, then
+	// detaches and terminates them
+	Remove
+	// Restore brings the Auto Scaling group back to its size before the surge
+	Restore
+)
+
+// Capacity holds group sizes to set or restore, nil sizes are left unchanged
+type Capacity struct {
+	Min     *int64 `json:"Min,omitempty"`
+	Max     *int64 `json:"Max,omitempty"`
+	Desired *int64 `json:"Desired,omitempty"`
+}
+
+// Alert is saved and notified as JSON, fields may be added but not renamed
 type Alert struct {
-	Type              Type
-	Status            Status
-	Trigger           Trigger
-	EventCount        int64
-	ClusterArn        string
-	ContainerInstanceArn string
-	AlertDate         time.Time
-	LastActionDate    time.Time
+	Type              Type      `json:"Type"`
+	Status            Status    `json:"Status"`
+	Trigger           Trigger   `json:"Trigger"`
+	EventCount        int64     `json:"EventCount"`
+	ClusterArn        string    `json:"ClusterArn"`
+	AccountId         string    `json:"AccountId"`
+	ContainerInstanceArn string `json:"ContainerInstanceArn"`
+	// ContainerInstanceArns are the instances a ScaleDown alert is draining,
+	// or the ones a Retire alert is replacing
+	ContainerInstanceArns []string `json:"ContainerInstanceArns,omitempty"`
+	Capacity          *Capacity `json:"Capacity,omitempty"`
+	// Step is how far a Retire alert has got
+	Step              Step      `json:"Step"`
+	// InstanceCount is how many instances a ScaleUp alert needs at least,
+	// zero when the cluster utilization alone sizes it
+	InstanceCount     int64     `json:"InstanceCount"`
+	// DrainStartDate is when the alert's instances started draining, an
+	// Escalation alert carries the one of the drain it escalates
+	DrainStartDate    time.Time `json:"DrainStartDate"`
+	AlertDate         time.Time `json:"AlertDate"`
+	LastActionDate    time.Time `json:"LastActionDate"`
+	// History holds the alert's status changes and actions, oldest first
+	History           []HistoryEntry `json:"History,omitempty"`
 }
 
-func (a Alert) String() string{
-	alertType := "?"
-	switch  a.Type{
+func (t Type) String() string {
+	switch t {
 	case ScaleUp:
-		alertType = "ScaleUp"
+		return "ScaleUp"
 	case ScaleDown:
-		alertType = "ScaleDown"
+		return "ScaleDown"
 	case Retire:
-		alertType = "Retire"
+		return "Retire"
+	case Escalation:
+		return "Escalation"
 	}
+	return "?"
+}
 
-	alertStatus := "?"
-	switch a.Status{
+func (s Status) String() string {
+	switch s {
 	case Created:
-		alertStatus = "Created"
+		return "Created"
 	case Pending:
-		alertStatus = "Pending"
+		return "Pending"
 	case InProgress:
-		alertStatus = "InProgress"
+		return "InProgress"
 	case Completed:
-		alertStatus = "Completed"
+		return "Completed"
+	case Failed:
+		return "Failed"
+	case Cancelled:
+		return "Cancelled"
 	}
+	return "?"
+}
 
-	alertTrigger := "?"
-	switch a.Trigger{
+func (s Step) String() string {
+	switch s {
+	case Surge:
+		return "Surge"
+	case AwaitReplacement:
+		return "AwaitReplacement"
+	case Drain:
+		return "Drain"
+	case Remove:
+		return "Remove"
+	case Restore:
+		return "Restore"
+	}
+	return "?"
+}
+
+func (t Trigger) String() string {
+	switch t {
 	case Resources:
-		alertTrigger = "Resources"
+		return "Resources"
 	case Schedule:
-		alertTrigger = "Schedule"
+		return "Schedule"
 	case Service:
-		alertTrigger = "Service"
+		return "Service"
 	case Instance:
-		alertTrigger = "Instance"
+		return "Instance"
+	case Image:
+		return "Image"
+	case DrainTimeout:
+		return "DrainTimeout"
 	}
+	return "?"
+}
+
+func (a Alert) String() string{
+	return fmt.Sprintf("Cluster: %s Account: %s Count: %d AlertType: %s AlertTrigger: %s AlertStatus: %s Instance: %s", a.ClusterArn, a.AccountId, a.EventCount, a.Type, a.Trigger, a.Status, a.ContainerInstanceArn) + formatHistory(a.History)
+}
 
-	return fmt.Sprintf("Cluster: %s Count: %d AlertType: %s AlertTrigger: %s AlertStatus: %s Instance: %s", a.ClusterArn, a.EventCount, alertType, alertTrigger, alertStatus, a.ContainerInstanceArn)
+//accountId returns the account part of arn:aws:ecs:region:account:cluster/name
+func accountId(clusterArn string) string {
+	parts := strings.SplitN(clusterArn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
 }
 
 
@@ -89,6 +184,7 @@ func NewAlert(alertType Type, alertTrigger Trigger, clusterArn string, container
 		Trigger:           alertTrigger,
 		EventCount:        1,
 		ClusterArn:        clusterArn,
+		AccountId:         accountId(clusterArn),
 		ContainerInstanceArn: containerInstanceArn,
 		AlertDate:         time.Now(),
 		LastActionDate:    time.Now(),
@@ -120,6 +216,7 @@ func ConsolidateAlerts(alerts []*Alert) []*Alert {
 	newScaleUpAlerts := make([]*Alert, 0)
 	newScaleDownAlerts := make([]*Alert, 0)
 	newRetireAlerts := make([]*Alert, 0)
+	newScheduleAlerts := make([]*Alert, 0)
 	reOccurringAlerts := make([]*Alert, 0)
 
 	scaleUpPending := false
@@ -134,6 +231,12 @@ func ConsolidateAlerts(alerts []*Alert) []*Alert {
 	//group up alerts by their type, status, and trigger
 	for _, alertItem := range alerts {
 
+		//scheduled alerts act on their own and do not wait to re-occur
+		if alertItem.Trigger == Schedule && alertItem.Status == Created {
+			newScheduleAlerts = append(newScheduleAlerts, alertItem)
+			continue
+		}
+
 		if alertItem.Type == ScaleUp && alertItem.Status == Created {
 			newScaleUpAlerts = append(newScaleUpAlerts, alertItem)
 		}
@@ -163,20 +266,34 @@ func ConsolidateAlerts(alerts []*Alert) []*Alert {
 	//check if there are any re-occurring events and if they need to be marked as incremented or removed
 	i := 0
 	if len(reOccurringAlerts) > 0 {
-		for _, alert := range reOccurringAlerts {
+		//range over a copy, DeleteAlertFromArray shifts the slice it is given
+		for _, alert := range append([]*Alert{}, reOccurringAlerts...) {
 			alert.EventCount += 1
-			if alert.Type == ScaleUp && alert.Status == Pending {
+			if alert.Trigger == Schedule {
+				//scheduled alerts are kept until they are reconciled
+			} else if alert.Type == ScaleUp && alert.Status == Pending {
 				if len(newScaleUpAlerts) == 0 {
+					alert.Transition(Cancelled, "condition cleared")
 					reOccurringAlerts = DeleteAlertFromArray(reOccurringAlerts, i)
 					i-=1
+				} else {
+					//size the pending alert for the latest demand
+					alert.InstanceCount = 0
+					for _, newAlert := range newScaleUpAlerts {
+						if newAlert.InstanceCount > alert.InstanceCount {
+							alert.InstanceCount = newAlert.InstanceCount
+						}
+					}
 				}
 			} else if alert.Type == ScaleDown && alert.Status == Pending {
 				if len(newScaleDownAlerts) == 0 {
+					alert.Transition(Cancelled, "condition cleared")
 					reOccurringAlerts = DeleteAlertFromArray(reOccurringAlerts, i)
 					i-=1
 				}
 			} else if alert.Type == Retire && alert.Status == Pending {
 				if !AlertsContainInstanceArn(newRetireAlerts, alert.ContainerInstanceArn) {
+					alert.Transition(Cancelled, "condition cleared")
 					reOccurringAlerts = DeleteAlertFromArray(reOccurringAlerts, i)
 					i-=1
 				}
@@ -190,21 +307,26 @@ func ConsolidateAlerts(alerts []*Alert) []*Alert {
 
 	if len(newScaleUpAlerts) > 0 && !scaleUpPending {
 		scaleUpPending = true
-		newScaleUpAlerts[0].Status = Pending
+		newScaleUpAlerts[0].Transition(Pending, "raised")
 		response = append(response, newScaleUpAlerts[0])
 	}
 
 	if len(newScaleDownAlerts) > 0 && !scaleDownPending{
 		scaleDownPending = true
-		newScaleDownAlerts[0].Status = Pending
+		newScaleDownAlerts[0].Transition(Pending, "raised")
 		response = append(response, newScaleDownAlerts[0])
 	}
 
 	if len(newRetireAlerts) > 0 && !retirePending{
 		retirePending = true
-		newRetireAlerts[0].Status = Pending
+		newRetireAlerts[0].Transition(Pending, "raised")
 		response = append(response, newRetireAlerts[0])
 	}
 
+	for _, alertItem := range newScheduleAlerts {
+		alertItem.Transition(Pending, "scheduled")
+		response = append(response, alertItem)
+	}
+
 	return response
 }
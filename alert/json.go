@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// unknown is what String returns for a value outside its enum
+const unknown = "?"
+
+// ParseType returns the Type named name, as returned by String
+func ParseType(name string) (Type, error) {
+	for t := Type(0); t.String() != unknown; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown alert type %q", name)
+}
+
+// ParseStatus returns the Status named name, as returned by String
+func ParseStatus(name string) (Status, error) {
+	for s := Status(0); s.String() != unknown; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown alert status %q", name)
+}
+
+// ParseTrigger returns the Trigger named name, as returned by String
+func ParseTrigger(name string) (Trigger, error) {
+	for t := Trigger(0); t.String() != unknown; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown alert trigger %q", name)
+}
+
+// ParseStep returns the Step named name, as returned by String
+func ParseStep(name string) (Step, error) {
+	for s := Step(0); s.String() != unknown; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown alert step %q", name)
+}
+
+// marshalName writes an enum value by name, refusing values without one
+func marshalName(kind string, value int, name string) ([]byte, error) {
+	if name == unknown {
+		return nil, fmt.Errorf("unknown alert %s %d", kind, value)
+	}
+	return json.Marshal(name)
+}
+
+// unmarshalName reads an enum by name, or by number as saved before
+func unmarshalName(kind string, data []byte, parse func(string) error, valid func(int) bool) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return parse(name)
+	}
+
+	var number int
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("alert %s must be a name, got %s", kind, data)
+	}
+	if !valid(number) {
+		return fmt.Errorf("unknown alert %s %d", kind, number)
+	}
+	return nil
+}
+
+func (t Type) MarshalJSON() ([]byte, error) {
+	return marshalName("type", int(t), t.String())
+}
+
+func (t *Type) UnmarshalJSON(data []byte) error {
+	return unmarshalName("type", data, func(name string) (err error) {
+		*t, err = ParseType(name)
+		return err
+	}, func(number int) bool {
+		*t = Type(number)
+		return t.String() != unknown
+	})
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	return marshalName("status", int(s), s.String())
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	return unmarshalName("status", data, func(name string) (err error) {
+		*s, err = ParseStatus(name)
+		return err
+	}, func(number int) bool {
+		*s = Status(number)
+		return s.String() != unknown
+	})
+}
+
+func (t Trigger) MarshalJSON() ([]byte, error) {
+	return marshalName("trigger", int(t), t.String())
+}
+
+func (t *Trigger) UnmarshalJSON(data []byte) error {
+	return unmarshalName("trigger", data, func(name string) (err error) {
+		*t, err = ParseTrigger(name)
+		return err
+	}, func(number int) bool {
+		*t = Trigger(number)
+		return t.String() != unknown
+	})
+}
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	return marshalName("step", int(s), s.String())
+}
+
+func (s *Step) UnmarshalJSON(data []byte) error {
+	return unmarshalName("step", data, func(name string) (err error) {
+		*s, err = ParseStep(name)
+		return err
+	}, func(number int) bool {
+		*s = Step(number)
+		return s.String() != unknown
+	})
+}
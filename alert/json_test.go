@@ -0,0 +1,82 @@
+package alert
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertJSONRoundTrip(t *testing.T) {
+	desired := int64(4)
+	alert := NewAlert(Retire, Image, "arn:aws:ecs:us-west-2:123456789012:cluster/web", "instance/1")
+	alert.Transition(Pending, "raised")
+	alert.Transition(InProgress, "replacements launched")
+	alert.RecordAction("IncreaseCapacity", "launch replacements", "")
+	alert.Step = Drain
+	alert.ContainerInstanceArns = []string{"instance/1", "instance/2"}
+	alert.Capacity = &Capacity{Desired: &desired}
+	alert.DrainStartDate = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	data, err := json.Marshal(alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"Type":"Retire"`, `"Status":"InProgress"`, `"Trigger":"Image"`, `"Step":"Drain"`, `"From":"Pending"`, `"Capacity":{"Desired":4}`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("%s missing from %s", field, data)
+		}
+	}
+
+	decoded := &Alert{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.ContainerInstanceArns, alert.ContainerInstanceArns) || *decoded.Capacity.Desired != 4 || !decoded.DrainStartDate.Equal(alert.DrainStartDate) {
+		t.Fatalf("decoded %+v, want %+v", decoded, alert)
+	}
+	//whatever the decoded alert holds encodes back the same
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Fatalf("encoded again as %s, want %s", again, data)
+	}
+}
+
+func TestAlertJSONDecodesLegacyNumbers(t *testing.T) {
+	//alerts saved before the enums were named hold their numbers
+	legacy := `{"Type": 1, "Status": 2, "Trigger": 3, "Step": 0, "EventCount": 5,
+		"History": [{"From": 1, "To": 2}]}`
+
+	decoded := &Alert{}
+	if err := json.Unmarshal([]byte(legacy), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != ScaleDown || decoded.Status != InProgress || decoded.Trigger != Instance || decoded.Step != Surge || decoded.EventCount != 5 {
+		t.Fatalf("decoded %v at step %s, want an InProgress ScaleDown raised by Instance", decoded, decoded.Step)
+	}
+	if len(decoded.History) != 1 || decoded.History[0].From != Pending || decoded.History[0].To != InProgress {
+		t.Fatalf("decoded history %v, want Pending->InProgress", decoded.History)
+	}
+}
+
+func TestAlertJSONRejectsUnknownValues(t *testing.T) {
+	tests := []string{
+		`{"Type": "Resize"}`,
+		`{"Status": 42}`,
+		`{"Trigger": true}`,
+		`{"Step": "Detach"}`,
+	}
+	for _, document := range tests {
+		if err := json.Unmarshal([]byte(document), &Alert{}); err == nil {
+			t.Errorf("decoded %s, want an error", document)
+		}
+	}
+
+	if _, err := json.Marshal(&Alert{Type: Type(42)}); err == nil {
+		t.Error("encoded an alert of unknown type, want an error")
+	}
+}
@@ -0,0 +1,127 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxHistory bounds the entries kept in an alert's History, the oldest are
+// dropped first
+const maxHistory = 50
+
+// HistoryEntry is a status change of an alert, or an action taken for it
+type HistoryEntry struct {
+	Date time.Time `json:"Date"`
+	From Status    `json:"From"`
+	To   Status    `json:"To"`
+	// Action is the action taken, empty for a status change
+	Action string `json:"Action,omitempty"`
+	Reason string `json:"Reason,omitempty"`
+	Error  string `json:"Error,omitempty"`
+}
+
+func (h HistoryEntry) String() string {
+	text := fmt.Sprintf("%s %s->%s", h.Date.Format(time.RFC3339), h.From, h.To)
+	if h.Action != "" {
+		text = fmt.Sprintf("%s %s", h.Date.Format(time.RFC3339), h.Action)
+	}
+	if h.Reason != "" {
+		text += " (" + h.Reason + ")"
+	}
+	if h.Error != "" {
+		text += " error: " + h.Error
+	}
+	return text
+}
+
+// formatHistory appends History to an alert's log line, empty without any
+func formatHistory(history []HistoryEntry) string {
+	if len(history) == 0 {
+		return ""
+	}
+	entries := make([]string, 0, len(history))
+	for _, entry := range history {
+		entries = append(entries, entry.String())
+	}
+	return " History: [" + strings.Join(entries, "; ") + "]"
+}
+
+// transitions lists the statuses each status may move to, by alert type.
+// Completed, Failed and Cancelled are final.
+var transitions = map[Type]map[Status][]Status{
+	ScaleUp: {
+		Created:    {Pending, Cancelled},
+		Pending:    {InProgress, Completed, Failed, Cancelled},
+		InProgress: {Completed, Failed, Cancelled},
+	},
+	ScaleDown: {
+		Created: {Pending, Cancelled},
+		Pending: {InProgress, Completed, Failed, Cancelled},
+		//a scheduled scale down drains more instances until its size is reached
+		InProgress: {Pending, Completed, Failed, Cancelled},
+	},
+	Retire: {
+		Created:    {Pending, Cancelled},
+		Pending:    {InProgress, Completed, Failed, Cancelled},
+		InProgress: {Completed, Failed, Cancelled},
+	},
+	Escalation: {
+		Created:    {InProgress, Cancelled},
+		InProgress: {Completed, Cancelled},
+	},
+}
+
+// TransitionError is returned for a status change the alert's type does not allow
+type TransitionError struct {
+	Type Type
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s alert cannot go from %s to %s", e.Type, e.From, e.To)
+}
+
+// CanTransition reports whether an alert of type alertType may go from one
+// status to the other
+func CanTransition(alertType Type, from Status, to Status) bool {
+	for _, allowed := range transitions[alertType][from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Final reports whether an alert in this status will not change any more
+func (s Status) Final() bool {
+	return s == Completed || s == Failed || s == Cancelled
+}
+
+// Transition records the change in History, an illegal one returns a *TransitionError
+func (a *Alert) Transition(status Status, reason string) error {
+	if !CanTransition(a.Type, a.Status, status) {
+		return &TransitionError{Type: a.Type, From: a.Status, To: status}
+	}
+	a.record(HistoryEntry{Date: time.Now(), From: a.Status, To: status, Reason: reason})
+	if a.Status != Created {
+		a.EventCount = 0
+	}
+	a.Status = status
+	return nil
+}
+
+// RecordAction adds an action taken for the alert to its History and sets its
+// LastActionDate, errMessage is empty when the action succeeded
+func (a *Alert) RecordAction(action string, reason string, errMessage string) {
+	a.LastActionDate = time.Now()
+	a.record(HistoryEntry{Date: a.LastActionDate, From: a.Status, To: a.Status, Action: action, Reason: reason, Error: errMessage})
+}
+
+func (a *Alert) record(entry HistoryEntry) {
+	a.History = append(a.History, entry)
+	if len(a.History) > maxHistory {
+		a.History = append([]HistoryEntry{}, a.History[len(a.History)-maxHistory:]...)
+	}
+}
@@ -0,0 +1,140 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var statuses = []Status{Created, Pending, InProgress, Completed, Failed, Cancelled}
+
+func TestCanTransition(t *testing.T) {
+	//every edge allowed for each type, any other is rejected
+	tests := []struct {
+		alertType Type
+		allowed   []string
+	}{
+		{ScaleUp, []string{
+			"Created->Pending", "Created->Cancelled",
+			"Pending->InProgress", "Pending->Completed", "Pending->Failed", "Pending->Cancelled",
+			"InProgress->Completed", "InProgress->Failed", "InProgress->Cancelled",
+		}},
+		{ScaleDown, []string{
+			"Created->Pending", "Created->Cancelled",
+			"Pending->InProgress", "Pending->Completed", "Pending->Failed", "Pending->Cancelled",
+			"InProgress->Pending", "InProgress->Completed", "InProgress->Failed", "InProgress->Cancelled",
+		}},
+		{Retire, []string{
+			"Created->Pending", "Created->Cancelled",
+			"Pending->InProgress", "Pending->Completed", "Pending->Failed", "Pending->Cancelled",
+			"InProgress->Completed", "InProgress->Failed", "InProgress->Cancelled",
+		}},
+		{Escalation, []string{
+			"Created->InProgress", "Created->Cancelled",
+			"InProgress->Completed", "InProgress->Cancelled",
+		}},
+	}
+	for _, test := range tests {
+		allowed := make(map[string]bool)
+		for _, edge := range test.allowed {
+			allowed[edge] = true
+		}
+		for _, from := range statuses {
+			for _, to := range statuses {
+				edge := fmt.Sprintf("%s->%s", from, to)
+				if got := CanTransition(test.alertType, from, to); got != allowed[edge] {
+					t.Errorf("%s %s allowed = %t, want %t", test.alertType, edge, got, allowed[edge])
+				}
+			}
+		}
+	}
+}
+
+func TestTransitionRejectsIllegalChange(t *testing.T) {
+	alert := NewAlert(Escalation, DrainTimeout, "cluster", "instance")
+	alert.EventCount = 4
+
+	err := alert.Transition(Pending, "not for escalations")
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("Transition error = %v, want a *TransitionError", err)
+	}
+	if transitionErr.Type != Escalation || transitionErr.From != Created || transitionErr.To != Pending {
+		t.Fatalf("TransitionError = %+v, want Escalation from Created to Pending", transitionErr)
+	}
+	if err.Error() != "Escalation alert cannot go from Created to Pending" {
+		t.Fatalf("error text %q", err.Error())
+	}
+	if alert.Status != Created || alert.EventCount != 4 || len(alert.History) != 0 {
+		t.Fatalf("alert %v with %d history entries, want it unchanged", alert, len(alert.History))
+	}
+}
+
+func TestTransitionRecordsHistory(t *testing.T) {
+	alert := NewAlert(ScaleUp, Resources, "cluster", "")
+	alert.EventCount = 2
+
+	//the pass spent Created counts toward Pending, later changes restart the count
+	if err := alert.Transition(Pending, "raised"); err != nil {
+		t.Fatal(err)
+	}
+	if alert.EventCount != 2 {
+		t.Fatalf("EventCount = %d after Created->Pending, want 2 kept", alert.EventCount)
+	}
+	if err := alert.Transition(InProgress, "capacity increased"); err != nil {
+		t.Fatal(err)
+	}
+	if alert.EventCount != 0 {
+		t.Fatalf("EventCount = %d after Pending->InProgress, want 0", alert.EventCount)
+	}
+	alert.RecordAction("IncreaseCapacity", "scale up", "")
+
+	if len(alert.History) != 3 {
+		t.Fatalf("history = %v, want two changes and an action", alert.History)
+	}
+	last := alert.History[1]
+	if last.From != Pending || last.To != InProgress || last.Reason != "capacity increased" || last.Action != "" {
+		t.Fatalf("history entry %+v, want Pending->InProgress", last)
+	}
+	if action := alert.History[2]; action.Action != "IncreaseCapacity" || alert.LastActionDate.IsZero() {
+		t.Fatalf("history entry %+v, want the IncreaseCapacity action", action)
+	}
+	if text := alert.String(); !strings.Contains(text, "Pending->InProgress (capacity increased); ") || !strings.Contains(text, "IncreaseCapacity (scale up)]") {
+		t.Fatalf("String() = %q, want the History in it", text)
+	}
+}
+
+func TestRecordActionKeepsHistoryBounded(t *testing.T) {
+	alert := NewAlert(ScaleUp, Resources, "cluster", "")
+	for i := 0; i < maxHistory+10; i++ {
+		alert.RecordAction("IncreaseCapacity", fmt.Sprintf("attempt %d", i), "")
+	}
+	if len(alert.History) != maxHistory || alert.History[0].Reason != "attempt 10" {
+		t.Fatalf("kept %d entries starting at %q, want the last %d", len(alert.History), alert.History[0].Reason, maxHistory)
+	}
+}
+
+func TestFailedAndCancelledAreFinal(t *testing.T) {
+	for _, status := range statuses {
+		want := status == Completed || status == Failed || status == Cancelled
+		if status.Final() != want {
+			t.Errorf("%s final = %t, want %t", status, status.Final(), want)
+		}
+	}
+
+	for _, final := range []Status{Failed, Cancelled} {
+		alert := NewAlert(Retire, Instance, "cluster", "instance")
+		if err := alert.Transition(Pending, "raised"); err != nil {
+			t.Fatal(err)
+		}
+		if err := alert.Transition(final, "stopped"); err != nil {
+			t.Fatalf("Pending->%s: %v", final, err)
+		}
+		for _, to := range statuses {
+			if err := alert.Transition(to, "reopened"); err == nil {
+				t.Errorf("%s alert moved on to %s", final, to)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package check
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Check produces alerts for a cluster, registered by name
+type Check interface {
+	// Name identifies the check in the Checks config key, logs and metrics
+	Name() string
+	// Enabled reports whether the check runs with the given cluster settings
+	Enabled(settings *config.Config) bool
+	// Evaluate returns the alerts the check raises for the cluster
+	Evaluate(cluster *ecs.ClusterDetails, settings *config.Config) ([]*alert.Alert, error)
+}
+
+// Result is the outcome of one check against one cluster
+type Result struct {
+	Name     string
+	Alerts   []*alert.Alert
+	Duration time.Duration
+	Error    error
+}
+
+var registry = struct {
+	sync.RWMutex
+	checks []Check
+}{}
+
+// Register adds a check to the ones Run evaluates, in registration order.
+// It panics when a check with the same name is already registered.
+func Register(check Check) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, registered := range registry.checks {
+		if registered.Name() == check.Name() {
+			panic(fmt.Sprintf("check %s registered twice", check.Name()))
+		}
+	}
+	registry.checks = append(registry.checks, check)
+}
+
+// Registered returns every registered check in registration order
+func Registered() []Check {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	checks := make([]Check, len(registry.checks))
+	copy(checks, registry.checks)
+	return checks
+}
+
+// Lookup returns the registered check with the given name, or nil
+func Lookup(name string) Check {
+	for _, check := range Registered() {
+		if check.Name() == name {
+			return check
+		}
+	}
+	return nil
+}
+
+// Run evaluates every enabled check, a failing one does not stop the others
+func Run(cluster *ecs.ClusterDetails, settings *config.Config) []*Result {
+	results := make([]*Result, 0)
+	for _, check := range Registered() {
+		if !check.Enabled(settings) {
+			continue
+		}
+
+		started := time.Now()
+		alerts, err := check.Evaluate(cluster, settings)
+		result := &Result{
+			Name:     check.Name(),
+			Alerts:   alerts,
+			Duration: time.Since(started),
+			Error:    err,
+		}
+		results = append(results, result)
+		metrics.CheckEvaluated(*cluster.ClusterArn, result.Name, result.Duration, len(result.Alerts), err)
+
+		fields := logrus.Fields{
+			"ClusterArn": *cluster.ClusterArn,
+			"Check":      result.Name,
+			"Alerts":     len(result.Alerts),
+			"Duration":   result.Duration,
+		}
+		if err != nil {
+			logrus.WithFields(fields).Error(err)
+			continue
+		}
+		logrus.WithFields(fields).Debug("Check Evaluated")
+	}
+	return results
+}
+
+// Func is a check made of a function, enabled through the Checks config key
+type Func struct {
+	name     string
+	evaluate func(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert
+}
+
+// NewFunc returns a check named name that runs evaluate
+func NewFunc(name string, evaluate func(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert) *Func {
+	return &Func{name: name, evaluate: evaluate}
+}
+
+func (f *Func) Name() string {
+	return f.name
+}
+
+func (f *Func) Enabled(settings *config.Config) bool {
+	return settings.CheckEnabled(f.name)
+}
+
+func (f *Func) Evaluate(cluster *ecs.ClusterDetails, settings *config.Config) ([]*alert.Alert, error) {
+	return f.evaluate(cluster, settings), nil
+}
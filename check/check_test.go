@@ -0,0 +1,73 @@
+package check
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/ecs"
+)
+
+// failingCheck always returns its error
+type failingCheck struct {
+	name string
+}
+
+func (f *failingCheck) Name() string {
+	return f.name
+}
+
+func (f *failingCheck) Enabled(settings *config.Config) bool {
+	return true
+}
+
+func (f *failingCheck) Evaluate(cluster *ecs.ClusterDetails, settings *config.Config) ([]*alert.Alert, error) {
+	return nil, fmt.Errorf("%s failed", f.name)
+}
+
+func TestRunEvaluatesEnabledChecksInOrder(t *testing.T) {
+	scaleUp := func(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert {
+		return []*alert.Alert{alert.NewAlert(alert.ScaleUp, alert.Resources, *cluster.ClusterArn, "")}
+	}
+	Register(NewFunc("TestFirst", scaleUp))
+	Register(&failingCheck{name: "TestFailing"})
+	Register(NewFunc("TestDisabled", scaleUp))
+	Register(NewFunc("TestLast", scaleUp))
+
+	settings, err := config.Parse([]byte(`{"Checks": {"TestDisabled": {"Enabled": false}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &ecs.ClusterDetails{ClusterArn: aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/web")}
+
+	results := Run(cluster, settings)
+	names := make([]string, 0)
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	if fmt.Sprint(names) != "[TestFirst TestFailing TestLast]" {
+		t.Fatalf("ran %v, want TestFirst, TestFailing and TestLast", names)
+	}
+	if results[1].Error == nil || len(results[1].Alerts) != 0 {
+		t.Fatalf("failing check result = %+v, want its error", results[1])
+	}
+	if len(results[2].Alerts) != 1 || results[2].Error != nil {
+		t.Fatalf("last check result = %+v, want one alert after the failure", results[2])
+	}
+
+	if Lookup("TestLast") == nil || Lookup("TestMissing") != nil {
+		t.Fatal("Lookup did not find exactly the registered checks")
+	}
+}
+
+func TestRegisterRefusesDuplicateNames(t *testing.T) {
+	Register(NewFunc("TestDuplicate", nil))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering TestDuplicate twice did not panic")
+		}
+	}()
+	Register(NewFunc("TestDuplicate", nil))
+}
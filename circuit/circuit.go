@@ -0,0 +1,134 @@
+package circuit
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	// Closed lets every action through
+	Closed State = iota
+	// Open refuses actions until the cooldown has passed
+	Open
+	// HalfOpen lets actions through on trial, the next failure opens the
+	// breaker again and the next success closes it
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "Closed"
+	case Open:
+		return "Open"
+	case HalfOpen:
+		return "HalfOpen"
+	}
+	return "?"
+}
+
+// MarshalText lets the status API report states by name
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Breaker stops acting on a cluster after too many consecutive failed
+// checks, giving AWS or the cluster time to recover before trying again
+type Breaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	open      bool
+}
+
+// NewBreaker returns a closed breaker that opens after threshold consecutive
+// failures and stays open for cooldown
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Configure changes the threshold and cooldown, keeping the breaker's state
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.threshold = threshold
+	b.cooldown = cooldown
+}
+
+// State returns the state of the breaker at now
+func (b *Breaker) State(now time.Time) State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state(now)
+}
+
+func (b *Breaker) state(now time.Time) State {
+	if !b.open {
+		return Closed
+	}
+	if now.Sub(b.openedAt) < b.cooldown {
+		return Open
+	}
+	return HalfOpen
+}
+
+// Allow reports whether actions may be taken at now
+func (b *Breaker) Allow(now time.Time) bool {
+	return b.State(now) != Open
+}
+
+// Failures returns the number of consecutive failures recorded
+func (b *Breaker) Failures() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.failures
+}
+
+// Success clears the failures and closes a half-open breaker
+func (b *Breaker) Success(now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state(now) == Open {
+		return
+	}
+	b.failures = 0
+	b.open = false
+}
+
+// Failure reports whether the failure opened the breaker
+func (b *Breaker) Failure(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	switch b.state(now) {
+	case HalfOpen:
+		b.openedAt = now
+		return true
+	case Closed:
+		if b.failures >= b.threshold {
+			b.open = true
+			b.openedAt = now
+			return true
+		}
+	}
+	return false
+}
+
+// Trip opens the breaker at now regardless of the threshold, for failures
+// such as rejected credentials that retrying soon will not fix
+func (b *Breaker) Trip(now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	b.open = true
+	b.openedAt = now
+}
@@ -0,0 +1,79 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	type step struct {
+		at      int
+		event   string
+		opened  bool
+		state   State
+		failure int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens at the threshold", []step{
+			{0, "failure", false, Closed, 1},
+			{1, "failure", false, Closed, 2},
+			{2, "failure", true, Open, 3},
+			{59, "", false, Open, 3},
+		}},
+		{"a success resets the count", []step{
+			{0, "failure", false, Closed, 1},
+			{1, "failure", false, Closed, 2},
+			{2, "success", false, Closed, 0},
+			{3, "failure", false, Closed, 1},
+		}},
+		{"half-open after the cooldown, closed by a success", []step{
+			{0, "trip", false, Open, 1},
+			{60, "", false, HalfOpen, 1},
+			{61, "success", false, Closed, 0},
+		}},
+		{"half-open reopened by a failure", []step{
+			{0, "trip", false, Open, 1},
+			{60, "failure", true, Open, 2},
+			{119, "", false, Open, 2},
+			{120, "", false, HalfOpen, 2},
+		}},
+		{"a success while open waits out the cooldown", []step{
+			{0, "trip", false, Open, 1},
+			{30, "success", false, Open, 1},
+			{60, "", false, HalfOpen, 1},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breaker := NewBreaker(3, time.Minute)
+			for _, step := range test.steps {
+				opened := false
+				switch step.event {
+				case "failure":
+					opened = breaker.Failure(at(step.at))
+				case "success":
+					breaker.Success(at(step.at))
+				case "trip":
+					breaker.Trip(at(step.at))
+				}
+				state := breaker.State(at(step.at))
+				if opened != step.opened || state != step.state || breaker.Failures() != step.failure {
+					t.Fatalf("%s at %ds: opened %t, %s with %d failures, want opened %t, %s with %d failures",
+						step.event, step.at, opened, state, breaker.Failures(), step.opened, step.state, step.failure)
+				}
+				if breaker.Allow(at(step.at)) != (state != Open) {
+					t.Fatalf("Allow at %ds disagrees with state %s", step.at, state)
+				}
+			}
+		})
+	}
+}
@@ -2,53 +2,668 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"os"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/sd-charris/ecs-manager/notify"
+	"github.com/sd-charris/ecs-manager/schedule"
 	"github.com/sirupsen/logrus"
 )
 
-var ConfigSettings map[string]string
+// Config holds config.json, values may be strings or native JSON types
+type Config struct {
+	// Region hosts the manager's own resources: CloudWatch logs, state and leases
+	Region string `scope:"global"`
+	// Regions are searched for clusters to manage, defaults to Region
+	Regions []string `scope:"global"`
+	// Accounts are managed by assuming each RoleArn, empty manages the own account only
+	Accounts                       []Account `scope:"global"`
+	IntervalSeconds                int64     `scope:"global"`
+	AlertIntervalCount             int64
+	AlertCooldownIntervalCount     int64
+	InstanceMaxAgeDays             int64
+	ResourceRemoveThresholdPercent float64
+	ResourceAddThresholdPercent    float64
+	DryRun                         bool
+	StateStore                     string `scope:"global"`
+	StateFile                      string `scope:"global"`
+	StateTable                     string `scope:"global"`
+	LeaderElection                 string `scope:"global"`
+	LeaderLeaseFile                string `scope:"global"`
+	LeaderTable                    string `scope:"global"`
+	// LeaderLeaseSeconds defaults to three check intervals when zero
+	LeaderLeaseSeconds int64  `scope:"global"`
+	LeaderIdentity     string `scope:"global"`
+	HTTPListenAddress  string `scope:"global"`
+	// Retry settings bound the backoff of throttled or transient describe calls
+	RetryMaxAttempts      int64 `scope:"global"`
+	RetryBaseMilliseconds int64 `scope:"global"`
+	RetryMaxMilliseconds  int64 `scope:"global"`
+	// ShutdownTimeoutSeconds bounds how long a SIGTERM or SIGINT waits for the
+	// HTTP server and notifications in flight once the last check finished
+	ShutdownTimeoutSeconds int64 `scope:"global"`
+	// IncludeClusters and ExcludeClusters hold cluster names, arns, globs or
+	// regex:<expression> patterns; an empty IncludeClusters manages every cluster
+	IncludeClusters []string `scope:"global"`
+	ExcludeClusters []string `scope:"global"`
+	// ClusterOptInTags, when set, limits management to clusters carrying every
+	// tag, e.g. {"ecs-manager:enabled": "true"}; a value of "*" accepts any value
+	ClusterOptInTags map[string]string `scope:"global"`
+	// ScaleUpMaxStep and ScaleDownMaxStep bound how many instances a single
+	// scale up adds and a single scale down drains
+	ScaleUpMaxStep   int64
+	ScaleDownMaxStep int64
+	// CircuitBreakerThreshold consecutive failed passes over a cluster stop
+	// its actions for CircuitBreakerCooldownSeconds; the cluster is still checked
+	CircuitBreakerThreshold       int64
+	CircuitBreakerCooldownSeconds int64
+	// DrainTimeoutSeconds before DrainTimeoutPolicy "escalate", "stop-tasks" or "terminate" applies, 0 waits forever
+	DrainTimeoutSeconds int64
+	DrainTimeoutPolicy  string
+	// AMIRefresh is "launch-template", "ssm" or "off"
+	AMIRefresh           string
+	AMIRefreshParameter  string
+	AMIRefreshBatchSize  int64
+	AMIRefreshSurgeCount int64
+	// PendingTaskThresholdSeconds is how long a task may stay PROVISIONING or
+	// PENDING before it counts toward a scale up
+	PendingTaskThresholdSeconds int64
+	// Checks holds each check's settings by name, e.g. {"PendingTasks": {"Enabled": false}}
+	Checks map[string]map[string]interface{}
+	// Schedules are a JSON list such as ["weekdays 07:00 America/Los_Angeles set min 6"]
+	Schedules []string
+	// ScheduleCatchUpSeconds is how far back schedules are run after a
+	// restart or leader change
+	ScheduleCatchUpSeconds int64
+	// Notifiers receive the alert status changes made while reconciling
+	Notifiers []Notifier
+
+	overrides []*clusterOverride
+}
+
+// Account is reached by assuming RoleArn, empty uses the manager's credentials
+type Account struct {
+	RoleArn    string
+	ExternalId string
+}
 
+// Notifier sends alert status changes to Slack, an SNS topic or a webhook
+type Notifier struct {
+	// Type is slack, sns or webhook
+	Type string
+	// URL is the Slack incoming webhook or the webhook endpoint
+	URL string
+	// TopicArn is the SNS topic, published to with the manager's credentials
+	TopicArn string
+	// Headers are added to webhook requests, e.g. an Authorization header
+	Headers map[string]string
+	// Events are Type, Type:Status or Type:From->To patterns, * for any
+	Events []string
+	// Template is a text/template rendered with the notification event,
+	// notify.DefaultTemplate when empty
+	Template string
+	// MaxAttempts and BackoffMilliseconds bound delivery retries, the backoff
+	// doubling after each one; zero uses 3 attempts and 1000 milliseconds
+	MaxAttempts         int64
+	BackoffMilliseconds int64
+}
 
-// LoadConfig reads the given file parsing it and returning a
-// ConfigSetting splice
-func LoadConfig(fileName string) {
+// roleArnPattern matches arn:aws:iam::<account id>:role/<name> in every partition
+var roleArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/.+$`)
 
-	ConfigSettings = make(map[string]string)
-	file, e := ioutil.ReadFile(fileName)
-	if e != nil {
-		logrus.Error("File error: %v\n", e)
-		os.Exit(1)
+// topicArnPattern matches arn:aws:sns:<region>:<account id>:<topic> in every partition
+var topicArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:\d{12}:.+$`)
+
+// clusterOverride holds the settings of one "Clusters" block
+type clusterOverride struct {
+	match  string
+	regex  *regexp.Regexp
+	values map[string]json.RawMessage
+}
+
+// matches reports whether the override applies to the named cluster
+func (o *clusterOverride) matches(clusterName string, clusterArn string) bool {
+	if o.regex != nil {
+		return o.regex.MatchString(clusterName) || o.regex.MatchString(clusterArn)
+	}
+	for _, candidate := range []string{clusterName, clusterArn} {
+		if matched, _ := path.Match(o.match, candidate); matched {
+			return true
+		}
 	}
-	json.Unmarshal(file, &ConfigSettings)
+	return false
 }
 
+// validate returns a problem for every notifier setting that cannot be used
+func (n *Notifier) validate() []string {
+	problems := make([]string, 0)
 
-func GetConfigValueAsFloat64(name string) *float64 {
+	switch n.Type {
+	case "slack", "webhook":
+		if _, err := url.ParseRequestURI(n.URL); err != nil || !strings.HasPrefix(n.URL, "http") {
+			problems = append(problems, fmt.Sprintf("URL: expected an http or https url, got %q", n.URL))
+		}
+	case "sns":
+		if !topicArnPattern.MatchString(n.TopicArn) {
+			problems = append(problems, fmt.Sprintf("TopicArn: expected an SNS topic arn, got %q", n.TopicArn))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("Type: must be slack, sns or webhook, got %q", n.Type))
+	}
+	for _, pattern := range n.Events {
+		if err := notify.ValidPattern(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("Events: %s", err))
+		}
+	}
+	if n.Template != "" {
+		if _, err := notify.ParseTemplate(n.Template); err != nil {
+			problems = append(problems, fmt.Sprintf("Template: %s", err))
+		}
+	}
+	if n.MaxAttempts < 0 {
+		problems = append(problems, fmt.Sprintf("MaxAttempts: must not be negative, got %d", n.MaxAttempts))
+	}
+	if n.BackoffMilliseconds < 0 {
+		problems = append(problems, fmt.Sprintf("BackoffMilliseconds: must not be negative, got %d", n.BackoffMilliseconds))
+	}
+	return problems
+}
 
-	if ConfigSettings != nil{
-		if val, ok := ConfigSettings[name]; ok {
-			res, err := strconv.ParseFloat(val, 64)
-			if err != nil {
-				return nil
-			}
-			return &res
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+var current = Default()
+
+// Default returns the configuration used for keys missing from config.json
+func Default() *Config {
+	return &Config{
+		Region:                         "us-west-2",
+		IntervalSeconds:                5,
+		AlertIntervalCount:             4,
+		AlertCooldownIntervalCount:     5,
+		InstanceMaxAgeDays:             7,
+		ScheduleCatchUpSeconds:         300,
+		ResourceRemoveThresholdPercent: 0.40,
+		ResourceAddThresholdPercent:    0.80,
+		ScaleUpMaxStep:                 5,
+		ScaleDownMaxStep:               2,
+		PendingTaskThresholdSeconds:    60,
+		DrainTimeoutSeconds:            3600,
+		DrainTimeoutPolicy:             "escalate",
+		AMIRefreshParameter:            "/aws/service/ecs/optimized-ami/amazon-linux-2/recommended/image_id",
+		AMIRefreshBatchSize:            1,
+		AMIRefreshSurgeCount:           1,
+		StateFile:                      "./state.json",
+		StateTable:                     "ecs-manager-state",
+		LeaderLeaseFile:                "./leader.json",
+		LeaderTable:                    "ecs-manager-leader",
+		ShutdownTimeoutSeconds:         20,
+		RetryMaxAttempts:               3,
+		RetryBaseMilliseconds:          200,
+		RetryMaxMilliseconds:           5000,
+		CircuitBreakerThreshold:        3,
+		CircuitBreakerCooldownSeconds:  300,
+	}
+}
+
+// Get returns the configuration loaded by LoadConfig, or the defaults
+func Get() *Config {
+	return current
+}
+
+// LoadConfig reads and validates the given file, making it the configuration
+// returned by Get
+func LoadConfig(fileName string) (*Config, error) {
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+
+	cfg, err := Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	current = cfg
+	return cfg, nil
+}
+
+// Parse decodes a configuration document over the defaults and validates it
+func Parse(document []byte) (*Config, error) {
+	var values map[string]json.RawMessage
+	err := json.Unmarshal(document, &values)
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+
+	cfg := Default()
+	problems := make([]string, 0)
+
+	var defaults map[string]json.RawMessage
+	if section, ok := values["Defaults"]; ok {
+		delete(values, "Defaults")
+		if err := json.Unmarshal(section, &defaults); err != nil {
+			problems = append(problems, fmt.Sprintf("Defaults: expected an object, got %s", section))
+		}
+	}
+
+	var clusters []map[string]json.RawMessage
+	if section, ok := values["Clusters"]; ok {
+		delete(values, "Clusters")
+		if err := json.Unmarshal(section, &clusters); err != nil {
+			problems = append(problems, fmt.Sprintf("Clusters: expected a list of objects, got %s", section))
 		}
 	}
+
+	problems = append(problems, cfg.apply(values, false)...)
+	problems = append(problems, cfg.apply(defaults, false)...)
+	if len(cfg.Regions) == 0 {
+		cfg.Regions = []string{cfg.Region}
+	}
+	problems = append(problems, cfg.validate()...)
+
+	for i, values := range clusters {
+		override, overrideProblems := cfg.newClusterOverride(values)
+		for _, problem := range overrideProblems {
+			problems = append(problems, fmt.Sprintf("Clusters[%d]: %s", i, problem))
+		}
+		if override != nil {
+			cfg.overrides = append(cfg.overrides, override)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// newClusterOverride parses one "Clusters" block, checking that its settings
+// are valid once applied over c
+func (c *Config) newClusterOverride(values map[string]json.RawMessage) (*clusterOverride, []string) {
+	override := &clusterOverride{values: values}
+
+	var match string
+	if json.Unmarshal(values["Match"], &match) != nil || match == "" {
+		return nil, []string{"Match: expected a cluster name, arn, glob or regex:<expression>"}
+	}
+	delete(values, "Match")
+	override.match = match
+
+	if err := validPattern(match); err != nil {
+		return nil, []string{fmt.Sprintf("Match: %s", err)}
+	}
+	if strings.HasPrefix(match, "regex:") {
+		override.regex = regexp.MustCompile(strings.TrimPrefix(match, "regex:"))
+	}
+
+	effective := *c
+	problems := effective.apply(values, true)
+	problems = append(problems, effective.validate()...)
+	for key := range values {
+		if fieldType, ok := reflect.TypeOf(effective).FieldByName(key); !ok || fieldType.PkgPath != "" {
+			delete(values, key)
+		}
+	}
+	for i := range problems {
+		problems[i] = fmt.Sprintf("(%s) %s", match, problems[i])
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return override, nil
+}
+
+// String lists every setting as Key=Value
+func (c Config) String() string {
+	value := reflect.ValueOf(c)
+	settings := make([]string, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		settings = append(settings, fmt.Sprintf("%s=%v", value.Type().Field(i).Name, value.Field(i).Interface()))
+	}
+	return strings.Join(settings, " ")
+}
+
+// ForCluster returns the settings for the named cluster: c with every
+// matching "Clusters" block applied in the order they appear
+func (c *Config) ForCluster(clusterName string, clusterArn string) *Config {
+	effective := *c
+	for _, override := range c.overrides {
+		if override.matches(clusterName, clusterArn) {
+			effective.apply(override.values, true)
+		}
+	}
+	return &effective
+}
+
+// Overrides returns the Match key of every "Clusters" block that applies to the named cluster
+func (c *Config) Overrides(clusterName string, clusterArn string) []string {
+	matches := make([]string, 0)
+	for _, override := range c.overrides {
+		if override.matches(clusterName, clusterArn) {
+			matches = append(matches, override.match)
+		}
+	}
+	return matches
+}
+
+// CheckEnabled reports whether the named check runs, checks are enabled
+// unless their settings set Enabled to false
+func (c *Config) CheckEnabled(name string) bool {
+	switch enabled := c.Checks[name]["Enabled"].(type) {
+	case bool:
+		return enabled
+	case string:
+		parsed, err := strconv.ParseBool(enabled)
+		return err != nil || parsed
+	}
+	return true
+}
+
+// DecodeCheck decodes the named check's settings into target
+func (c *Config) DecodeCheck(name string, target interface{}) error {
+	settings, ok := c.Checks[name]
+	if !ok {
+		return nil
+	}
+	document, err := json.Marshal(settings)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	err = json.Unmarshal(document, target)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
 	return nil
 }
 
-func GetConfigValueAsInt64(name string) *int64{
+// apply sets the field named by each key, returning a problem per bad value
+func (c *Config) apply(values map[string]json.RawMessage, clusterScope bool) []string {
+	problems := make([]string, 0)
+	target := reflect.ValueOf(c).Elem()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldType, ok := target.Type().FieldByName(key)
+		if !ok || fieldType.PkgPath != "" {
+			logrus.WithFields(logrus.Fields{
+				"Key": key,
+			}).Warn("Ignoring unknown configuration key")
+			continue
+		}
+		if clusterScope && fieldType.Tag.Get("scope") == "global" {
+			problems = append(problems, fmt.Sprintf("%s: cannot be overridden per cluster", key))
+			continue
+		}
+
+		field := target.FieldByIndex(fieldType.Index)
+
+		err := setField(field, values[key])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+	return problems
+}
 
-	if ConfigSettings != nil{
-		if val, ok := ConfigSettings[name]; ok {
-			res, err := strconv.ParseInt(val, 10,64)
-			if err != nil {
-				return nil
+// setField decodes value into field, accepting the value quoted as a string
+func setField(field reflect.Value, value json.RawMessage) error {
+	//maps are merged into, copy them so a cluster override never changes the
+	//map it shares with the configuration it was applied over
+	if field.Kind() == reflect.Map && !field.IsNil() {
+		copied := reflect.MakeMapWithSize(field.Type(), field.Len())
+		for _, key := range field.MapKeys() {
+			copied.SetMapIndex(key, field.MapIndex(key))
+		}
+		field.Set(copied)
+	}
+	//slices are decoded over their backing array, start from a new one for the
+	//same reason
+	if field.Kind() == reflect.Slice {
+		field.Set(reflect.New(field.Type()).Elem())
+	}
+
+	err := json.Unmarshal(value, field.Addr().Interface())
+	if err == nil || field.Kind() == reflect.String {
+		return err
+	}
+
+	expected := map[reflect.Kind]string{
+		reflect.Int64:   "an integer",
+		reflect.Float64: "a number",
+		reflect.Bool:    "true or false",
+		reflect.Slice:   "a list",
+		reflect.Map:     "an object",
+	}[field.Kind()]
+
+	var text string
+	if json.Unmarshal(value, &text) != nil {
+		return fmt.Errorf("expected %s, got %s", expected, value)
+	}
+	text = strings.TrimSpace(text)
+
+	switch field.Kind() {
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("expected %s, got %s", expected, value)
+		}
+		list := make([]string, 0)
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
 			}
-			return &res
 		}
+		field.Set(reflect.ValueOf(list))
+	case reflect.Int64:
+		parsed, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected %s, got %q", expected, text)
+		}
+		field.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("expected %s, got %q", expected, text)
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("expected %s, got %q", expected, text)
+		}
+		field.SetBool(parsed)
+	default:
+		return fmt.Errorf("unsupported value %s", value)
 	}
 	return nil
 }
+
+// validPattern checks a glob or regex:<expression> cluster pattern
+func validPattern(pattern string) error {
+	if strings.HasPrefix(pattern, "regex:") {
+		_, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:"))
+		return err
+	}
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// validate returns a problem for every setting outside its allowed range
+func (c *Config) validate() []string {
+	problems := make([]string, 0)
+
+	if c.Region == "" {
+		problems = append(problems, "Region: required")
+	}
+	if c.IntervalSeconds <= 0 {
+		problems = append(problems, fmt.Sprintf("IntervalSeconds: must be positive, got %d", c.IntervalSeconds))
+	}
+	if c.AlertIntervalCount < 0 {
+		problems = append(problems, fmt.Sprintf("AlertIntervalCount: must not be negative, got %d", c.AlertIntervalCount))
+	}
+	if c.AlertCooldownIntervalCount < 0 {
+		problems = append(problems, fmt.Sprintf("AlertCooldownIntervalCount: must not be negative, got %d", c.AlertCooldownIntervalCount))
+	}
+	if c.InstanceMaxAgeDays <= 0 {
+		problems = append(problems, fmt.Sprintf("InstanceMaxAgeDays: must be positive, got %d", c.InstanceMaxAgeDays))
+	}
+	if c.ResourceRemoveThresholdPercent < 0 || c.ResourceRemoveThresholdPercent > 1 {
+		problems = append(problems, fmt.Sprintf("ResourceRemoveThresholdPercent: must be between 0 and 1, got %g", c.ResourceRemoveThresholdPercent))
+	}
+	if c.ResourceAddThresholdPercent < 0 || c.ResourceAddThresholdPercent > 1 {
+		problems = append(problems, fmt.Sprintf("ResourceAddThresholdPercent: must be between 0 and 1, got %g", c.ResourceAddThresholdPercent))
+	}
+	if c.ResourceRemoveThresholdPercent >= c.ResourceAddThresholdPercent {
+		problems = append(problems, fmt.Sprintf("ResourceRemoveThresholdPercent: must be below ResourceAddThresholdPercent (%g), got %g", c.ResourceAddThresholdPercent, c.ResourceRemoveThresholdPercent))
+	}
+	if c.ShutdownTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("ShutdownTimeoutSeconds: must not be negative, got %d", c.ShutdownTimeoutSeconds))
+	}
+	if c.RetryMaxAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("RetryMaxAttempts: must be at least 1, got %d", c.RetryMaxAttempts))
+	}
+	if c.RetryBaseMilliseconds < 0 {
+		problems = append(problems, fmt.Sprintf("RetryBaseMilliseconds: must not be negative, got %d", c.RetryBaseMilliseconds))
+	}
+	if c.RetryMaxMilliseconds < c.RetryBaseMilliseconds {
+		problems = append(problems, fmt.Sprintf("RetryMaxMilliseconds: must be at least RetryBaseMilliseconds (%d), got %d", c.RetryBaseMilliseconds, c.RetryMaxMilliseconds))
+	}
+	if c.CircuitBreakerThreshold < 1 {
+		problems = append(problems, fmt.Sprintf("CircuitBreakerThreshold: must be at least 1, got %d", c.CircuitBreakerThreshold))
+	}
+	if c.CircuitBreakerCooldownSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("CircuitBreakerCooldownSeconds: must not be negative, got %d", c.CircuitBreakerCooldownSeconds))
+	}
+	if c.ScaleUpMaxStep < 1 {
+		problems = append(problems, fmt.Sprintf("ScaleUpMaxStep: must be at least 1, got %d", c.ScaleUpMaxStep))
+	}
+	if c.ScaleDownMaxStep < 1 {
+		problems = append(problems, fmt.Sprintf("ScaleDownMaxStep: must be at least 1, got %d", c.ScaleDownMaxStep))
+	}
+	checkNames := make([]string, 0, len(c.Checks))
+	for name := range c.Checks {
+		checkNames = append(checkNames, name)
+	}
+	sort.Strings(checkNames)
+	for _, name := range checkNames {
+		switch enabled := c.Checks[name]["Enabled"].(type) {
+		case nil, bool:
+		case string:
+			if _, err := strconv.ParseBool(enabled); err != nil {
+				problems = append(problems, fmt.Sprintf("Checks.%s.Enabled: expected true or false, got %q", name, enabled))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("Checks.%s.Enabled: expected true or false, got %v", name, enabled))
+		}
+	}
+	if c.DrainTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("DrainTimeoutSeconds: must not be negative, got %d", c.DrainTimeoutSeconds))
+	}
+	if !oneOf(c.DrainTimeoutPolicy, "escalate", "stop-tasks", "terminate") {
+		problems = append(problems, fmt.Sprintf("DrainTimeoutPolicy: must be escalate, stop-tasks or terminate, got %q", c.DrainTimeoutPolicy))
+	}
+	if !oneOf(c.AMIRefresh, "", "off", "launch-template", "ssm") {
+		problems = append(problems, fmt.Sprintf("AMIRefresh: must be launch-template, ssm or off, got %q", c.AMIRefresh))
+	}
+	if c.AMIRefresh == "ssm" && c.AMIRefreshParameter == "" {
+		problems = append(problems, "AMIRefreshParameter: required when AMIRefresh is ssm")
+	}
+	if c.AMIRefreshBatchSize < 1 {
+		problems = append(problems, fmt.Sprintf("AMIRefreshBatchSize: must be at least 1, got %d", c.AMIRefreshBatchSize))
+	}
+	if c.AMIRefreshSurgeCount < 0 {
+		problems = append(problems, fmt.Sprintf("AMIRefreshSurgeCount: must not be negative, got %d", c.AMIRefreshSurgeCount))
+	}
+	if c.PendingTaskThresholdSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("PendingTaskThresholdSeconds: must not be negative, got %d", c.PendingTaskThresholdSeconds))
+	}
+	for _, text := range c.Schedules {
+		if _, err := schedule.Parse(text); err != nil {
+			problems = append(problems, fmt.Sprintf("Schedules: %s", err))
+		}
+	}
+	if c.ScheduleCatchUpSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("ScheduleCatchUpSeconds: must not be negative, got %d", c.ScheduleCatchUpSeconds))
+	}
+	for i, notifier := range c.Notifiers {
+		for _, problem := range notifier.validate() {
+			problems = append(problems, fmt.Sprintf("Notifiers[%d].%s", i, problem))
+		}
+	}
+	if !oneOf(c.StateStore, "", "none", "file", "dynamodb") {
+		problems = append(problems, fmt.Sprintf("StateStore: must be file, dynamodb or none, got %q", c.StateStore))
+	}
+	if c.StateStore == "file" && c.StateFile == "" {
+		problems = append(problems, "StateFile: required when StateStore is file")
+	}
+	if c.StateStore == "dynamodb" && c.StateTable == "" {
+		problems = append(problems, "StateTable: required when StateStore is dynamodb")
+	}
+	if !oneOf(c.LeaderElection, "", "none", "memory", "file", "dynamodb") {
+		problems = append(problems, fmt.Sprintf("LeaderElection: must be file, dynamodb, memory or none, got %q", c.LeaderElection))
+	}
+	if c.LeaderElection == "file" && c.LeaderLeaseFile == "" {
+		problems = append(problems, "LeaderLeaseFile: required when LeaderElection is file")
+	}
+	if c.LeaderElection == "dynamodb" && c.LeaderTable == "" {
+		problems = append(problems, "LeaderTable: required when LeaderElection is dynamodb")
+	}
+	roleArns := make(map[string]bool)
+	for i, account := range c.Accounts {
+		if account.RoleArn != "" && !roleArnPattern.MatchString(account.RoleArn) {
+			problems = append(problems, fmt.Sprintf("Accounts[%d].RoleArn: expected arn:aws:iam::<account id>:role/<name>, got %q", i, account.RoleArn))
+		}
+		if account.RoleArn == "" && account.ExternalId != "" {
+			problems = append(problems, fmt.Sprintf("Accounts[%d].ExternalId: requires a RoleArn", i))
+		}
+		if roleArns[account.RoleArn] {
+			problems = append(problems, fmt.Sprintf("Accounts[%d].RoleArn: %q is listed more than once", i, account.RoleArn))
+		}
+		roleArns[account.RoleArn] = true
+	}
+	for _, pattern := range c.IncludeClusters {
+		if err := validPattern(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("IncludeClusters: %q: %s", pattern, err))
+		}
+	}
+	for _, pattern := range c.ExcludeClusters {
+		if err := validPattern(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("ExcludeClusters: %q: %s", pattern, err))
+		}
+	}
+	if c.LeaderLeaseSeconds < 0 || (c.LeaderLeaseSeconds > 0 && c.LeaderLeaseSeconds <= c.IntervalSeconds) {
+		problems = append(problems, fmt.Sprintf("LeaderLeaseSeconds: must be longer than IntervalSeconds (%d), got %d", c.IntervalSeconds, c.LeaderLeaseSeconds))
+	}
+
+	return problems
+}
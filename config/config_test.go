@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parse parses document, failing the test when it is invalid
+func parse(t *testing.T, document string) *Config {
+	t.Helper()
+	cfg, err := Parse([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestParseAcceptsOriginalStringValues(t *testing.T) {
+	//the config.json shipped before the typed Config, every value a string
+	original := parse(t, `{
+		"IntervalSeconds": "5",
+		"AlertIntervalCount": "4",
+		"AlertCooldownIntervalCount": "5",
+		"InstanceMaxAgeDays": "7",
+		"ResourceRemoveThresholdPercent": "0.40",
+		"ResourceAddThresholdPercent": "0.80",
+		"DryRun": "true"
+	}`)
+	native := parse(t, `{
+		"IntervalSeconds": 5,
+		"AlertIntervalCount": 4,
+		"AlertCooldownIntervalCount": 5,
+		"InstanceMaxAgeDays": 7,
+		"ResourceRemoveThresholdPercent": 0.40,
+		"ResourceAddThresholdPercent": 0.80,
+		"DryRun": true
+	}`)
+
+	if !reflect.DeepEqual(original, native) {
+		t.Fatalf("string values = %+v, want %+v", original, native)
+	}
+	if !original.DryRun || original.ResourceAddThresholdPercent != 0.80 {
+		t.Fatalf("parsed %+v, want DryRun and an add threshold of 0.80", original)
+	}
+}
+
+func TestParseFillsDefaults(t *testing.T) {
+	cfg := parse(t, `{"IntervalSeconds": 30, "SomeRetiredKey": "ignored"}`)
+
+	want := parse(t, `{}`)
+	want.IntervalSeconds = 30
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("parsed %+v, want %+v", cfg, want)
+	}
+}
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		problems []string
+	}{
+		{"not json", `{"IntervalSeconds": `, nil},
+		{"bad integer", `{"IntervalSeconds": "five"}`, []string{`IntervalSeconds: expected an integer, got "five"`}},
+		{"bad number", `{"ResourceAddThresholdPercent": true}`, []string{"ResourceAddThresholdPercent: expected a number, got true"}},
+		{"bad bool", `{"DryRun": "maybe"}`, []string{`DryRun: expected true or false, got "maybe"`}},
+		{"zero interval", `{"IntervalSeconds": 0}`, []string{"IntervalSeconds: must be positive, got 0"}},
+		{"thresholds crossed", `{"ResourceRemoveThresholdPercent": 0.9, "ResourceAddThresholdPercent": 0.5}`, []string{
+			"ResourceRemoveThresholdPercent: must be below ResourceAddThresholdPercent (0.5), got 0.9",
+		}},
+		{"threshold range", `{"ResourceAddThresholdPercent": 80}`, []string{"ResourceAddThresholdPercent: must be between 0 and 1, got 80"}},
+		{"unknown store", `{"StateStore": "redis"}`, []string{`StateStore: must be file, dynamodb or none, got "redis"`}},
+		{"missing state file", `{"StateStore": "file", "StateFile": ""}`, []string{"StateFile: required when StateStore is file"}},
+		{"short lease", `{"IntervalSeconds": 10, "LeaderLeaseSeconds": 10}`, []string{
+			"LeaderLeaseSeconds: must be longer than IntervalSeconds (10), got 10",
+		}},
+		{"every problem", `{"IntervalSeconds": -1, "InstanceMaxAgeDays": "x", "LeaderElection": "zookeeper"}`, []string{
+			`InstanceMaxAgeDays: expected an integer, got "x"`,
+			"IntervalSeconds: must be positive, got -1",
+			`LeaderElection: must be file, dynamodb, memory or none, got "zookeeper"`,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(test.document))
+			if err == nil {
+				t.Fatalf("parsed %+v, want an error", cfg)
+			}
+			if test.problems == nil {
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual(invalid.Problems, test.problems) {
+				t.Fatalf("problems = %q, want %q", invalid.Problems, test.problems)
+			}
+		})
+	}
+}
+
+func TestLoadConfigKeepsCurrentOnError(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(valid, []byte(`{"IntervalSeconds": "15"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(invalid, []byte(`{"IntervalSeconds": 0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { current = Default() }()
+
+	if _, err := LoadConfig(valid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(invalid); err == nil || !strings.Contains(err.Error(), "IntervalSeconds") {
+		t.Fatalf("loading an invalid file = %v, want an IntervalSeconds problem", err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("loading a missing file succeeded")
+	}
+	if interval := Get().IntervalSeconds; interval != 15 {
+		t.Fatalf("interval = %d after failed loads, want 15 from the last valid file", interval)
+	}
+}
+
+func TestForClusterAppliesMatchingOverrides(t *testing.T) {
+	cfg := parse(t, `{
+		"AlertIntervalCount": 4,
+		"Defaults": {"InstanceMaxAgeDays": 10},
+		"Clusters": [
+			{"Match": "web", "AlertIntervalCount": 2},
+			{"Match": "arn:aws:ecs:us-west-2:123456789012:cluster/api", "DryRun": true},
+			{"Match": "batch-*", "InstanceMaxAgeDays": 3},
+			{"Match": "regex:^batch-(nightly|weekly)$", "InstanceMaxAgeDays": 1}
+		]
+	}`)
+
+	tests := []struct {
+		cluster            string
+		alertIntervalCount int64
+		instanceMaxAgeDays int64
+		dryRun             bool
+		overrides          []string
+	}{
+		{"web", 2, 10, false, []string{"web"}},
+		{"api", 4, 10, true, []string{"arn:aws:ecs:us-west-2:123456789012:cluster/api"}},
+		{"batch-hourly", 4, 3, false, []string{"batch-*"}},
+		//later blocks win over earlier ones
+		{"batch-nightly", 4, 1, false, []string{"batch-*", "regex:^batch-(nightly|weekly)$"}},
+		{"worker", 4, 10, false, []string{}},
+	}
+	for _, test := range tests {
+		clusterArn := "arn:aws:ecs:us-west-2:123456789012:cluster/" + test.cluster
+		settings := cfg.ForCluster(test.cluster, clusterArn)
+		if settings.AlertIntervalCount != test.alertIntervalCount || settings.InstanceMaxAgeDays != test.instanceMaxAgeDays || settings.DryRun != test.dryRun {
+			t.Errorf("%s settings = %v", test.cluster, settings)
+		}
+		if overrides := cfg.Overrides(test.cluster, clusterArn); !reflect.DeepEqual(overrides, test.overrides) {
+			t.Errorf("%s overrides = %q, want %q", test.cluster, overrides, test.overrides)
+		}
+	}
+	if cfg.AlertIntervalCount != 4 || cfg.InstanceMaxAgeDays != 10 || cfg.DryRun {
+		t.Errorf("global settings changed to %v", cfg)
+	}
+}
+
+func TestParseRejectsInvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		problems []string
+	}{
+		{"no match", `{"Clusters": [{"DryRun": true}]}`, []string{
+			"Clusters[0]: Match: expected a cluster name, arn, glob or regex:<expression>",
+		}},
+		{"bad regex", `{"Clusters": [{"Match": "regex:("}]}`, []string{
+			"Clusters[0]: Match: error parsing regexp: missing closing ): `(`",
+		}},
+		{"global setting", `{"Clusters": [{"Match": "web", "IntervalSeconds": 10}]}`, []string{
+			"Clusters[0]: (web) IntervalSeconds: cannot be overridden per cluster",
+		}},
+		{"invalid once applied", `{"ResourceAddThresholdPercent": 0.5, "Clusters": [{"Match": "web", "ResourceRemoveThresholdPercent": 0.6}]}`, []string{
+			"Clusters[0]: (web) ResourceRemoveThresholdPercent: must be below ResourceAddThresholdPercent (0.5), got 0.6",
+		}},
+		{"not a list", `{"Clusters": {"Match": "web"}}`, []string{
+			`Clusters: expected a list of objects, got {"Match": "web"}`,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.document))
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual(invalid.Problems, test.problems) {
+				t.Fatalf("problems = %q, want %q", invalid.Problems, test.problems)
+			}
+		})
+	}
+}
+
+func TestClusterOverridesDoNotShareSchedules(t *testing.T) {
+	cfg := parse(t, `{
+		"Schedules": ["08:00 scale to 4", "20:00 scale to 2"],
+		"Clusters": [
+			{"Match": "web", "Schedules": ["23:00 scale to 1", "07:00 scale to 6"]},
+			{"Match": "batch", "Schedules": ["01:00 set max 20"]}
+		]
+	}`)
+	global := []string{"08:00 scale to 4", "20:00 scale to 2"}
+
+	tests := []struct {
+		cluster string
+		want    []string
+	}{
+		{"web", []string{"23:00 scale to 1", "07:00 scale to 6"}},
+		{"batch", []string{"01:00 set max 20"}},
+		{"api", global},
+		//again, after every override was applied
+		{"web", []string{"23:00 scale to 1", "07:00 scale to 6"}},
+		{"api", global},
+	}
+	for _, test := range tests {
+		settings := cfg.ForCluster(test.cluster, "arn:aws:ecs:us-west-2:123456789012:cluster/"+test.cluster)
+		if !reflect.DeepEqual(settings.Schedules, test.want) {
+			t.Errorf("%s schedules = %q, want %q", test.cluster, settings.Schedules, test.want)
+		}
+		if !reflect.DeepEqual(cfg.Schedules, global) {
+			t.Fatalf("global schedules = %q after %s, want %q", cfg.Schedules, test.cluster, global)
+		}
+	}
+}
+
+func TestClusterOverridesDoNotChangeEachOther(t *testing.T) {
+	cfg := parse(t, `{
+		"IncludeClusters": ["*"],
+		"ClusterOptInTags": {"team": "*"},
+		"Checks": {"PendingTasks": {"Enabled": true}},
+		"Clusters": [
+			{"Match": "web", "Checks": {"AMIRefresh": {"Enabled": false}}, "ScaleUpMaxStep": 2},
+			{"Match": "regex:^batch-", "Checks": {"PendingTasks": {"Enabled": false}}}
+		]
+	}`)
+
+	web := cfg.ForCluster("web", "")
+	batch := cfg.ForCluster("batch-nightly", "")
+	api := cfg.ForCluster("api", "")
+
+	if !web.CheckEnabled("PendingTasks") || web.CheckEnabled("AMIRefresh") || web.ScaleUpMaxStep != 2 {
+		t.Errorf("web settings = %v", web)
+	}
+	if batch.CheckEnabled("PendingTasks") || !batch.CheckEnabled("AMIRefresh") || batch.ScaleUpMaxStep != 5 {
+		t.Errorf("batch settings = %v", batch)
+	}
+	if !api.CheckEnabled("PendingTasks") || !api.CheckEnabled("AMIRefresh") || api.ScaleUpMaxStep != 5 {
+		t.Errorf("api settings = %v", api)
+	}
+	if len(cfg.Checks) != 1 || !cfg.CheckEnabled("PendingTasks") {
+		t.Errorf("global checks = %v, want only PendingTasks enabled", cfg.Checks)
+	}
+}
+
+func TestClusterOverridesDoNotShareNotifiers(t *testing.T) {
+	cfg := parse(t, `{
+		"Notifiers": [{"Type": "slack", "URL": "https://hooks.slack.com/services/T0/B0/global", "Events": ["*:InProgress"]}],
+		"Clusters": [
+			{"Match": "web", "Notifiers": [{"Type": "webhook", "URL": "https://example.com/web", "Events": ["ScaleDown"]}]},
+			{"Match": "batch", "Notifiers": [
+				{"Type": "sns", "TopicArn": "arn:aws:sns:us-west-2:123456789012:batch"},
+				{"Type": "webhook", "URL": "https://example.com/batch", "Headers": {"Authorization": "Bearer batch"}}
+			]}
+		]
+	}`)
+	global := []Notifier{{Type: "slack", URL: "https://hooks.slack.com/services/T0/B0/global", Events: []string{"*:InProgress"}}}
+
+	tests := []struct {
+		cluster string
+		want    []Notifier
+	}{
+		{"web", []Notifier{{Type: "webhook", URL: "https://example.com/web", Events: []string{"ScaleDown"}}}},
+		{"batch", []Notifier{
+			{Type: "sns", TopicArn: "arn:aws:sns:us-west-2:123456789012:batch"},
+			{Type: "webhook", URL: "https://example.com/batch", Headers: map[string]string{"Authorization": "Bearer batch"}},
+		}},
+		{"api", global},
+		{"web", []Notifier{{Type: "webhook", URL: "https://example.com/web", Events: []string{"ScaleDown"}}}},
+	}
+	for _, test := range tests {
+		settings := cfg.ForCluster(test.cluster, "")
+		if !reflect.DeepEqual(settings.Notifiers, test.want) {
+			t.Errorf("%s notifiers = %+v, want %+v", test.cluster, settings.Notifiers, test.want)
+		}
+		if !reflect.DeepEqual(cfg.Notifiers, global) {
+			t.Fatalf("global notifiers = %+v after %s, want %+v", cfg.Notifiers, test.cluster, global)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// drainTimedOut reports whether the instances of an alert have been draining
+// for longer than DrainTimeoutSeconds
+func (ecsCluster *ECSCluster) drainTimedOut(alertItem *alert.Alert) bool {
+	timeout := time.Duration(ecsCluster.Config.DrainTimeoutSeconds) * time.Second
+	return timeout > 0 && !alertItem.DrainStartDate.IsZero() && time.Since(alertItem.DrainStartDate) > timeout
+}
+
+// stopStandaloneTasks stops the tasks no service started on a draining
+// instance, ECS leaves those running and nothing else will stop them
+func (ecsCluster *ECSCluster) stopStandaloneTasks(alertItem *alert.Alert, containerInstance *ecs.ContainerInstance) {
+	cluster := ecsCluster.ClusterDetails
+	for _, task := range cluster.InstanceTasks(containerInstance.ContainerInstanceArn) {
+		if task.ServiceName() != "" || task.DesiredStatus == nil || *task.DesiredStatus != "RUNNING" {
+			continue
+		}
+		task := task
+		_, err := ecsCluster.perform(action.StopTask, alertItem, *containerInstance.ContainerInstanceArn, "drain exceeded DrainTimeoutSeconds", func() error {
+			return cluster.StopClusterTask(task, "ecs-manager: instance drain timed out")
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// reconcileEscalations raises an Escalation per timed out drain
+func (ecsCluster *ECSCluster) reconcileEscalations(escalations []*alert.Alert, drains []*alert.Alert) []*alert.Alert {
+	cluster := ecsCluster.ClusterDetails
+	open := make(map[*alert.Alert]bool)
+	for _, drain := range drains {
+		if drain.Status != alert.InProgress || len(drain.ContainerInstanceArns) == 0 || !ecsCluster.drainTimedOut(drain) {
+			continue
+		}
+
+		var escalation *alert.Alert
+		for _, candidate := range escalations {
+			if candidate.Status == alert.InProgress && candidate.ContainerInstanceArn == drain.ContainerInstanceArn && candidate.DrainStartDate.Equal(drain.DrainStartDate) {
+				escalation = candidate
+			}
+		}
+		if escalation == nil {
+			escalation = alert.NewAlert(alert.Escalation, alert.DrainTimeout, drain.ClusterArn, drain.ContainerInstanceArn)
+			transition(escalation, alert.InProgress, "drain exceeded DrainTimeoutSeconds")
+			escalation.EventCount = 0
+			escalation.DrainStartDate = drain.DrainStartDate
+			escalations = append(escalations, escalation)
+			metrics.AlertCreated(*cluster.ClusterArn, escalation.Type.String(), escalation.Trigger.String())
+			logrus.WithFields(logrus.Fields{
+				"Alert":          drain,
+				"Instances":      drain.ContainerInstanceArns,
+				"DrainStartDate": drain.DrainStartDate,
+				"Policy":         ecsCluster.Config.DrainTimeoutPolicy,
+			}).Error("Drain Timed Out")
+		}
+		escalation.ContainerInstanceArns = append([]string{}, drain.ContainerInstanceArns...)
+		open[escalation] = true
+	}
+
+	response := make([]*alert.Alert, 0, len(escalations))
+	for _, escalation := range escalations {
+		if escalation.Status == alert.InProgress && !open[escalation] {
+			logrus.WithFields(logrus.Fields{
+				"Alert": escalation,
+			}).Info("Timed out drain finished")
+			transition(escalation, alert.Completed, "drain finished")
+		}
+		if escalation.Status.Final() && escalation.EventCount > ecsCluster.Config.AlertCooldownIntervalCount {
+			continue
+		}
+		response = append(response, escalation)
+	}
+	return response
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsecs "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/ecs"
+)
+
+// startStuckDrain scales down an idle three instance cluster whose instances
+// each run a standalone task, which ECS never moves off a draining instance.
+// It returns the ScaleDown alert once its drain started, dated back past
+// DrainTimeoutSeconds.
+func startStuckDrain(t *testing.T, fake *ecs.FakeAWS, policy string) *alert.Alert {
+	t.Helper()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 5, 3, 1024, 2048)
+	fake.AddService(clusterArn, "api", 1, 128, 256)
+	clusters, err := ecs.NewFakeClient(fake).GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instance := range clusters[0].ContainerInstances {
+		fake.AddTask(clusterArn, *instance.ContainerInstanceArn, 64, 128)
+	}
+	newTestManager(t, fake, fmt.Sprintf(`{
+		"AlertIntervalCount": 1,
+		"ScaleDownMaxStep": 1,
+		"DrainTimeoutSeconds": 600,
+		"DrainTimeoutPolicy": %q
+	}`, policy))
+
+	for pass := 0; pass < 10; pass++ {
+		runPasses(t, 1)
+		for _, alertItem := range ecsClusters[clusterArn].Alerts {
+			if alertItem.Type == alert.ScaleDown && alertItem.Status == alert.InProgress {
+				alertItem.DrainStartDate = time.Now().Add(-time.Hour)
+				return alertItem
+			}
+		}
+	}
+	t.Fatal("no scale down started")
+	return nil
+}
+
+func countCalls(fake *ecs.FakeAWS, operation string) int {
+	count := 0
+	for _, call := range fake.Calls {
+		if call == operation {
+			count++
+		}
+	}
+	return count
+}
+
+// escalations returns the Escalation alerts of a cluster
+func escalations(clusterArn string) []*alert.Alert {
+	response := make([]*alert.Alert, 0)
+	for _, alertItem := range ecsClusters[clusterArn].Alerts {
+		if alertItem.Type == alert.Escalation {
+			response = append(response, alertItem)
+		}
+	}
+	return response
+}
+
+func TestDrainTimeoutEscalates(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	scaleDown := startStuckDrain(t, fake, "escalate")
+	drained := scaleDown.ContainerInstanceArns[0]
+
+	runPasses(t, 2)
+
+	raised := escalations(scaleDown.ClusterArn)
+	if len(raised) != 1 || raised[0].Status != alert.InProgress || raised[0].Trigger != alert.DrainTimeout {
+		t.Fatalf("escalations = %v, want one InProgress DrainTimeout escalation", raised)
+	}
+	if len(raised[0].ContainerInstanceArns) != 1 || raised[0].ContainerInstanceArns[0] != drained {
+		t.Fatalf("escalated instances %v, want %s", raised[0].ContainerInstanceArns, drained)
+	}
+	if len(fake.TerminatedInstanceIds) != 0 || countCalls(fake, "StopTask") != 0 {
+		t.Fatalf("terminated %v and stopped %d tasks, want the instance left to an operator", fake.TerminatedInstanceIds, countCalls(fake, "StopTask"))
+	}
+
+	//an operator stops the task, the drain finishes on its own and the
+	//escalation is closed on the pass after
+	for _, task := range fake.Clusters[0].Tasks {
+		if *task.ContainerInstanceArn == drained && *task.DesiredStatus == "RUNNING" {
+			if _, err := fake.StopTask(&awsecs.StopTaskInput{Cluster: aws.String(scaleDown.ClusterArn), Task: task.TaskArn}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	runPasses(t, 3)
+
+	if len(fake.TerminatedInstanceIds) != 1 {
+		t.Fatalf("terminated %v, want the drained instance", fake.TerminatedInstanceIds)
+	}
+	if raised := escalations(scaleDown.ClusterArn); len(raised) != 1 || raised[0].Status != alert.Completed {
+		t.Fatalf("escalations = %v, want the escalation Completed", raised)
+	}
+}
+
+func TestDrainTimeoutStopsStandaloneTasks(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	startStuckDrain(t, fake, "stop-tasks")
+
+	runPasses(t, 1)
+	if stopped := countCalls(fake, "StopTask"); stopped != 1 {
+		t.Fatalf("stopped %d tasks, want the standalone task on the drained instance", stopped)
+	}
+
+	runPasses(t, 1)
+	if len(fake.TerminatedInstanceIds) != 1 {
+		t.Fatalf("terminated %v, want the drained instance once its task stopped", fake.TerminatedInstanceIds)
+	}
+}
+
+func TestDrainTimeoutTerminates(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	scaleDown := startStuckDrain(t, fake, "terminate")
+
+	runPasses(t, 1)
+	if len(fake.TerminatedInstanceIds) != 1 || countCalls(fake, "StopTask") != 0 {
+		t.Fatalf("terminated %v and stopped %d tasks, want the instance removed with its task", fake.TerminatedInstanceIds, countCalls(fake, "StopTask"))
+	}
+	if scaleDown.Status != alert.Completed {
+		t.Fatalf("scale down alert %v, want Completed", scaleDown)
+	}
+}
@@ -0,0 +1,80 @@
+package ecs
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// ECSAPI is the subset of the ECS API used by the manager
+type ECSAPI interface {
+	ListClusters(input *ecs.ListClustersInput) (*ecs.ListClustersOutput, error)
+	DescribeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error)
+	ListContainerInstances(input *ecs.ListContainerInstancesInput) (*ecs.ListContainerInstancesOutput, error)
+	DescribeContainerInstances(input *ecs.DescribeContainerInstancesInput) (*ecs.DescribeContainerInstancesOutput, error)
+	ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
+	DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+	ListServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error)
+	DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error)
+	UpdateContainerInstancesState(input *ecs.UpdateContainerInstancesStateInput) (*ecs.UpdateContainerInstancesStateOutput, error)
+	StopTask(input *ecs.StopTaskInput) (*ecs.StopTaskOutput, error)
+}
+
+// AutoScalingAPI is the subset of the Auto Scaling API used by the manager
+type AutoScalingAPI interface {
+	DescribeAutoScalingInstances(input *autoscaling.DescribeAutoScalingInstancesInput) (*autoscaling.DescribeAutoScalingInstancesOutput, error)
+	DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
+	UpdateAutoScalingGroup(input *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error)
+	DescribeLaunchConfigurations(input *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error)
+	DetachInstances(input *autoscaling.DetachInstancesInput) (*autoscaling.DetachInstancesOutput, error)
+}
+
+// EC2API is the subset of the EC2 API used by the manager
+type EC2API interface {
+	TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error)
+	DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
+	DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error)
+}
+
+// SSMAPI is the subset of the SSM API used by the manager
+type SSMAPI interface {
+	GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error)
+}
+
+// Client holds the AWS clients used to read and change cluster state
+type Client struct {
+	ecsService         ECSAPI
+	autoscalingService AutoScalingAPI
+	ec2Service         EC2API
+	ssmService         SSMAPI
+	filter             *ClusterFilter
+	region             string
+}
+
+// NewClient returns a Client over the given services, real or FakeAWS
+func NewClient(ecsService ECSAPI, autoscalingService AutoScalingAPI, ec2Service EC2API, ssmService SSMAPI) *Client {
+	return &Client{
+		ecsService:         ecsService,
+		autoscalingService: autoscalingService,
+		ec2Service:         ec2Service,
+		ssmService:         ssmService,
+	}
+}
+
+// SetClusterFilter limits GetClusters to the clusters selected by filter,
+// nil selects every cluster
+func (client *Client) SetClusterFilter(filter *ClusterFilter) {
+	client.filter = filter
+}
+
+// SetRegion records the region the client's services talk to, it is carried
+// on every ClusterDetails the client returns
+func (client *Client) SetRegion(region string) {
+	client.region = region
+}
+
+// Region returns the region set by SetRegion
+func (client *Client) Region() string {
+	return client.region
+}
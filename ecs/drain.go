@@ -0,0 +1,79 @@
+package ecs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/go-errors/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// serviceGroupPrefix starts the group of every task a service started
+const serviceGroupPrefix = "service:"
+
+// ServiceName returns the name of the service that started the task, empty
+// for a standalone task
+func (t *Task) ServiceName() string {
+	if t.Group == nil || !strings.HasPrefix(*t.Group, serviceGroupPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(*t.Group, serviceGroupPrefix)
+}
+
+// IsDaemonTask reports whether the task belongs to a DAEMON service, those never hold up a drain
+func (c *ClusterDetails) IsDaemonTask(task *Task) bool {
+	name := task.ServiceName()
+	if name == "" {
+		return false
+	}
+	for _, service := range c.Services {
+		if aws.StringValue(service.ServiceName) == name {
+			return aws.StringValue(service.SchedulingStrategy) == ecs.SchedulingStrategyDaemon
+		}
+	}
+	return false
+}
+
+// InstanceTasks returns the tasks placed on a container instance
+func (c *ClusterDetails) InstanceTasks(containerInstanceArn *string) []*Task {
+	tasks := make([]*Task, 0)
+	for _, task := range c.Tasks {
+		if task.ContainerInstanceArn != nil && *task.ContainerInstanceArn == *containerInstanceArn {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// DrainBlockingTaskCount returns how many of the instance's running tasks
+// keep a drain from finishing: every task but the daemon tasks
+func (c *ClusterDetails) DrainBlockingTaskCount(containerInstance *ContainerInstance) int64 {
+	count := aws.Int64Value(containerInstance.RunningTasksCount)
+	for _, task := range c.InstanceTasks(containerInstance.ContainerInstanceArn) {
+		if c.IsDaemonTask(task) {
+			count--
+		}
+	}
+	if count < 0 {
+		return 0
+	}
+	return count
+}
+
+// StopClusterTask stops a task, giving ECS the reason shown on the stopped task
+func (c *ClusterDetails) StopClusterTask(task *Task, reason string) error {
+	logrus.WithFields(logrus.Fields{
+		"ClusterArn": *c.ClusterArn,
+		"AccountId":  c.AccountId,
+		"TaskArn":    *task.TaskArn,
+		"Reason":     reason,
+	}).Info("Stopping Cluster Task")
+
+	_, err := c.client.ecsService.StopTask(&ecs.StopTaskInput{Cluster: c.ClusterArn, Task: task.TaskArn, Reason: aws.String(reason)})
+	if err != nil {
+		logrus.Error(err)
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package ecs
+
+import (
+	"testing"
+)
+
+func TestDrainBlockingTaskCountIgnoresDaemonTasks(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 1, 1, 2048, 4096)
+	fake.AddService(clusterArn, "api", 2, 256, 512)
+	fake.AddDaemonService(clusterArn, "logs", 64, 128)
+	instanceArn := *describeFake(t, fake).ContainerInstances[0].ContainerInstanceArn
+	fake.AddTask(clusterArn, instanceArn, 128, 256)
+
+	cluster := describeFake(t, fake)
+	names := map[string]int{}
+	daemons := 0
+	for _, task := range cluster.Tasks {
+		names[task.ServiceName()]++
+		if cluster.IsDaemonTask(task) {
+			daemons++
+		}
+	}
+	if names["api"] != 2 || names["logs"] != 1 || names[""] != 1 || daemons != 1 {
+		t.Fatalf("service names %v with %d daemon tasks, want two api, one logs daemon and one standalone", names, daemons)
+	}
+
+	instance := cluster.ContainerInstances[0]
+	if count := cluster.DrainBlockingTaskCount(instance); count != 3 {
+		t.Fatalf("DrainBlockingTaskCount = %d of %d running, want every task but the daemon", count, *instance.RunningTasksCount)
+	}
+}
+
+func TestStopClusterTask(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 1, 1, 1024, 2048)
+	instanceArn := *describeFake(t, fake).ContainerInstances[0].ContainerInstanceArn
+	fake.AddTask(clusterArn, instanceArn, 128, 256)
+
+	task := fake.Clusters[0].Tasks[0]
+	cluster := describeFake(t, fake)
+	if err := cluster.StopClusterTask(cluster.Tasks[0], "drain timed out"); err != nil {
+		t.Fatal(err)
+	}
+	if *task.DesiredStatus != "STOPPED" || *task.StoppedReason != "drain timed out" {
+		t.Fatalf("task %s with reason %v, want STOPPED with the drain reason", *task.DesiredStatus, task.StoppedReason)
+	}
+	cluster = describeFake(t, fake)
+	if len(cluster.Tasks) != 0 {
+		t.Fatalf("described %d tasks after the stop, want none", len(cluster.Tasks))
+	}
+	if count := cluster.DrainBlockingTaskCount(cluster.ContainerInstances[0]); count != 0 {
+		t.Fatalf("DrainBlockingTaskCount = %d after the stop, want 0", count)
+	}
+}
@@ -1,21 +1,49 @@
 package ecs
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/go-errors/errors"
+	"github.com/sd-charris/ecs-manager/metrics"
 	"github.com/sirupsen/logrus"
 )
 
-var ecsService *ecs.ECS
-var autoscalingService *autoscaling.AutoScaling
-var ec2Service *ec2.EC2
+// defaultClients holds one Client per account and region configured by
+// Initialize, or the ones given to SetClients
+var defaultClients []*Client
+
+// DefaultRegion is managed when Initialize is given no regions
+const DefaultRegion = "us-west-2"
+
+// assumeRoleExpiryWindow renews assumed role credentials this long before they expire
+const assumeRoleExpiryWindow = time.Minute
+
+// Account is reached by assuming RoleArn, empty uses the manager's credentials
+type Account struct {
+	RoleArn    string
+	ExternalId string
+}
+
+// Maximum number of items AWS accepts in a single describe call
+const (
+	describeClustersLimit             = 100
+	describeContainerInstancesLimit   = 100
+	describeTasksLimit                = 100
+	describeServicesLimit             = 10
+	describeAutoScalingInstancesLimit = 50
+)
 
 type ServiceEvent struct {
 	CreatedAt *time.Time
@@ -24,20 +52,25 @@ type ServiceEvent struct {
 }
 
 type Service struct {
-	ServiceArn       *string
-	DesiredTaskCount *int64
-	CurrentTaskCount *int64
-	PendingTaskCount *int64
-	Events           []*ServiceEvent
+	ServiceArn         *string
+	ServiceName        *string
+	DesiredTaskCount   *int64
+	CurrentTaskCount   *int64
+	PendingTaskCount   *int64
+	// SchedulingStrategy is REPLICA or DAEMON
+	SchedulingStrategy *string
+	Events             []*ServiceEvent
 }
 
 type Task struct {
 	TaskArn              *string
 	ContainerInstanceArn *string
+	Group                *string
 	Status               *string
 	DesiredStatus        *string
 	CPU                  *int
 	Memory               *int
+	CreatedAt            *time.Time
 }
 
 type ContainerInstance struct {
@@ -53,10 +86,15 @@ type ContainerInstance struct {
 	PendingTasksCount    *int64
 	RunningTasksCount    *int64
 	AvailabilityZone     *string
+	// ImageId is the instance's AMI, set by DescribeImages
+	ImageId *string
 }
 
 type ClusterDetails struct {
 	ClusterArn           *string
+	ClusterName          *string
+	AccountId            string
+	Region               string
 	ContainerInstances   []*ContainerInstance
 	Tasks                []*Task
 	Services             []*Service
@@ -67,6 +105,10 @@ type ClusterDetails struct {
 	TotalRemainingCPU    int64
 	TotalRunningTasks    *int64
 	TotalPendingTasks    *int64
+	// TargetImageId is the AMI an image refresh moves the instances to,
+	// empty when no refresh check ran
+	TargetImageId string
+	client        *Client
 }
 
 type AutoScalingGroupDetails struct {
@@ -75,22 +117,56 @@ type AutoScalingGroupDetails struct {
 	MinInstanceCount     *int64
 	MaxInstanceCount     *int64
 	DesiredInstanceCount *int64
+	// LaunchTemplate or LaunchConfigurationName is what the group starts new instances from
+	LaunchTemplate          *autoscaling.LaunchTemplateSpecification
+	LaunchConfigurationName *string
 }
 
-//Initialize the ecs service
-func Initialize() {
+//Initialize the ecs service with one set of clients per account and region
+func Initialize(regions []string, accounts ...Account) {
+	if len(regions) == 0 {
+		regions = []string{DefaultRegion}
+	}
+	if len(accounts) == 0 {
+		accounts = []Account{{}}
+	}
+
 	// Load session from shared config
 	sessionOptions := session.Options{
-		Config:            aws.Config{Region: aws.String("us-west-2")},
+		Config:            aws.Config{Region: aws.String(regions[0])},
 		SharedConfigState: session.SharedConfigEnable,
 	}
 	sess := session.Must(session.NewSessionWithOptions(sessionOptions))
+	metrics.InstrumentHandlers(&sess.Handlers)
+
+	defaultClients = make([]*Client, 0, len(regions)*len(accounts))
+	for _, account := range accounts {
+		var creds *credentials.Credentials
+		if account.RoleArn != "" {
+			externalId := account.ExternalId
+			creds = stscreds.NewCredentials(sess, account.RoleArn, func(provider *stscreds.AssumeRoleProvider) {
+				provider.RoleSessionName = "ecs-manager"
+				provider.ExpiryWindow = assumeRoleExpiryWindow
+				if externalId != "" {
+					provider.ExternalID = aws.String(externalId)
+				}
+			})
+		}
 
-	// Create service client value configured for credentials
-	// from assumed role.
-	ecsService = ecs.New(sess)
-	autoscalingService = autoscaling.New(sess)
-	ec2Service = ec2.New(sess)
+		for _, region := range regions {
+			// Create service client value configured for credentials
+			// from assumed role.
+			regionSess := sess.Copy(&aws.Config{Region: aws.String(region), Credentials: creds})
+			client := NewClient(ecs.New(regionSess), autoscaling.New(regionSess), ec2.New(regionSess), ssm.New(regionSess))
+			client.SetRegion(region)
+			defaultClients = append(defaultClients, client)
+
+			logrus.WithFields(logrus.Fields{
+				"Region":  region,
+				"RoleArn": account.RoleArn,
+			}).Info("Configured AWS Clients")
+		}
+	}
 }
 
 func getResourceValue(attributes []*ecs.Resource, attributeName string) *int64 {
@@ -111,19 +187,40 @@ func getAttributeValue(attributes []*ecs.Attribute, attributeName string) *strin
 	return nil
 }
 
+// chunk splits items into consecutive slices of at most size items
+func chunk(items []*string, size int) [][]*string {
+	chunks := make([][]*string, 0)
+	for size < len(items) {
+		items, chunks = items[size:], append(chunks, items[0:size:size])
+	}
+	if len(items) > 0 {
+		chunks = append(chunks, items)
+	}
+	return chunks
+}
+
 func (c *ClusterDetails) getContainerInstances() error {
 	c.ContainerInstances = make([]*ContainerInstance, 0)
+	containerInstanceArns := make([]*string, 0)
 	reqContainerInstances := ecs.ListContainerInstancesInput{Cluster: c.ClusterArn}
-	resContainerInstances, err := ecsService.ListContainerInstances(&reqContainerInstances)
+	for {
+		resContainerInstances, err := c.client.ecsService.ListContainerInstances(&reqContainerInstances)
 
-	if err != nil {
-		logrus.Error(err)
-		return errors.Wrap(err, 1)
+		if err != nil {
+			logrus.Error(err)
+			return errors.Wrap(err, 1)
+		}
+
+		containerInstanceArns = append(containerInstanceArns, resContainerInstances.ContainerInstanceArns...)
+		if resContainerInstances.NextToken == nil {
+			break
+		}
+		reqContainerInstances.NextToken = resContainerInstances.NextToken
 	}
 
-	if len(resContainerInstances.ContainerInstanceArns) > 0 {
-		reqDescribeContainerInstances := ecs.DescribeContainerInstancesInput{Cluster: c.ClusterArn, ContainerInstances: resContainerInstances.ContainerInstanceArns}
-		resDescribeContainerInstances, err := ecsService.DescribeContainerInstances(&reqDescribeContainerInstances)
+	for _, containerInstanceArnsChunk := range chunk(containerInstanceArns, describeContainerInstancesLimit) {
+		reqDescribeContainerInstances := ecs.DescribeContainerInstancesInput{Cluster: c.ClusterArn, ContainerInstances: containerInstanceArnsChunk}
+		resDescribeContainerInstances, err := c.client.ecsService.DescribeContainerInstances(&reqDescribeContainerInstances)
 
 		if err != nil {
 			logrus.Error(err)
@@ -151,19 +248,49 @@ func (c *ClusterDetails) getContainerInstances() error {
 	return nil
 }
 
+//taskResourceValue parses a task level cpu or memory value, summing the
+//container level values when the task definition sets none
+func taskResourceValue(value *string, containers []*ecs.Container, containerValue func(*ecs.Container) *string) (int, error) {
+	if value != nil {
+		return strconv.Atoi(*value)
+	}
+
+	total := 0
+	for _, container := range containers {
+		if containerValue(container) == nil {
+			continue
+		}
+		parsed, err := strconv.Atoi(*containerValue(container))
+		if err != nil {
+			return 0, err
+		}
+		total += parsed
+	}
+	return total, nil
+}
+
 func (c *ClusterDetails) getTasks() error {
 	c.Tasks = make([]*Task, 0)
+	taskArns := make([]*string, 0)
 	req := ecs.ListTasksInput{Cluster: c.ClusterArn}
-	res, err := ecsService.ListTasks(&req)
+	for {
+		res, err := c.client.ecsService.ListTasks(&req)
 
-	if err != nil {
-		logrus.Error(err)
-		return errors.Wrap(err, 1)
+		if err != nil {
+			logrus.Error(err)
+			return errors.Wrap(err, 1)
+		}
+
+		taskArns = append(taskArns, res.TaskArns...)
+		if res.NextToken == nil {
+			break
+		}
+		req.NextToken = res.NextToken
 	}
 
-	if len(res.TaskArns) > 0 {
-		reqTaskdetails := ecs.DescribeTasksInput{Cluster: c.ClusterArn, Tasks: res.TaskArns}
-		resTaskDetails, err := ecsService.DescribeTasks(&reqTaskdetails)
+	for _, taskArnsChunk := range chunk(taskArns, describeTasksLimit) {
+		reqTaskdetails := ecs.DescribeTasksInput{Cluster: c.ClusterArn, Tasks: taskArnsChunk}
+		resTaskDetails, err := c.client.ecsService.DescribeTasks(&reqTaskdetails)
 
 		if err != nil {
 			logrus.Error(err)
@@ -175,20 +302,22 @@ func (c *ClusterDetails) getTasks() error {
 			clusterTask.TaskArn = task.TaskArn
 			clusterTask.Status = task.LastStatus
 			clusterTask.DesiredStatus = task.DesiredStatus
+			clusterTask.Group = task.Group
+			clusterTask.CreatedAt = task.CreatedAt
 
-			parseCPU, err := strconv.Atoi(*task.Cpu)
+			parseCPU, err := taskResourceValue(task.Cpu, task.Containers, func(container *ecs.Container) *string { return container.Cpu })
 			if err != nil {
 				logrus.Error(err)
 				return errors.Wrap(err, 1)
 			}
 			clusterTask.CPU = &parseCPU
 
-			parseMemory, err := strconv.Atoi(*task.Memory)
+			parseMemory, err := taskResourceValue(task.Memory, task.Containers, func(container *ecs.Container) *string { return container.Memory })
 			if err != nil {
 				logrus.Error(err)
 				return errors.Wrap(err, 1)
 			}
-			clusterTask.CPU = &parseMemory
+			clusterTask.Memory = &parseMemory
 
 			c.Tasks = append(c.Tasks, &clusterTask)
 		}
@@ -198,17 +327,26 @@ func (c *ClusterDetails) getTasks() error {
 
 func (c *ClusterDetails) getServices() error {
 	c.Services = make([]*Service, 0)
+	serviceArns := make([]*string, 0)
 	req := ecs.ListServicesInput{Cluster: c.ClusterArn}
-	res, err := ecsService.ListServices(&req)
+	for {
+		res, err := c.client.ecsService.ListServices(&req)
 
-	if err != nil {
-		logrus.Error(err)
-		return errors.Wrap(err, 1)
+		if err != nil {
+			logrus.Error(err)
+			return errors.Wrap(err, 1)
+		}
+
+		serviceArns = append(serviceArns, res.ServiceArns...)
+		if res.NextToken == nil {
+			break
+		}
+		req.NextToken = res.NextToken
 	}
 
-	if len(res.ServiceArns) > 0 {
-		reqServiceDetails := ecs.DescribeServicesInput{Cluster: c.ClusterArn, Services: res.ServiceArns}
-		resServiceDetails, err := ecsService.DescribeServices(&reqServiceDetails)
+	for _, serviceArnsChunk := range chunk(serviceArns, describeServicesLimit) {
+		reqServiceDetails := ecs.DescribeServicesInput{Cluster: c.ClusterArn, Services: serviceArnsChunk}
+		resServiceDetails, err := c.client.ecsService.DescribeServices(&reqServiceDetails)
 
 		if err != nil {
 			logrus.Error(err)
@@ -217,9 +355,11 @@ func (c *ClusterDetails) getServices() error {
 		for _, service := range resServiceDetails.Services {
 			var clusterService Service
 			clusterService.ServiceArn = service.ServiceArn
+			clusterService.ServiceName = service.ServiceName
 			clusterService.CurrentTaskCount = service.RunningCount
 			clusterService.DesiredTaskCount = service.DesiredCount
 			clusterService.PendingTaskCount = service.PendingCount
+			clusterService.SchedulingStrategy = service.SchedulingStrategy
 			clusterService.Events = make([]*ServiceEvent, 0)
 			for _, event := range service.Events {
 				var serviceEvent ServiceEvent
@@ -246,17 +386,25 @@ func (c *ClusterDetails) getAutoScalingGroups() error {
 	}
 
 	//describe the cluster instances in the cluster to try and find the autoscaling group they belong to
-	res, err := autoscalingService.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{InstanceIds: instanceIds})
+	var autoScalingGroupName *string
+	for _, instanceIdsChunk := range chunk(instanceIds, describeAutoScalingInstancesLimit) {
+		res, err := c.client.autoscalingService.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{InstanceIds: instanceIdsChunk})
 
-	if err != nil {
-		logrus.Error(err)
-		return errors.Wrap(err, 1)
+		if err != nil {
+			logrus.Error(err)
+			return errors.Wrap(err, 1)
+		}
+
+		if len(res.AutoScalingInstances) > 0 {
+			autoScalingGroupName = res.AutoScalingInstances[0].AutoScalingGroupName
+			break
+		}
 	}
 
-	if len(res.AutoScalingInstances) == 0 {
+	if autoScalingGroupName == nil {
 		logrus.Error("Could not find AutoScaling group")
 	} else {
-		resDescribeAutoScalingGroups, err := autoscalingService.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: []*string{res.AutoScalingInstances[0].AutoScalingGroupName}})
+		resDescribeAutoScalingGroups, err := c.client.autoscalingService.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: []*string{autoScalingGroupName}})
 
 		if err != nil {
 			logrus.Error(err)
@@ -265,11 +413,13 @@ func (c *ClusterDetails) getAutoScalingGroups() error {
 		if len(resDescribeAutoScalingGroups.AutoScalingGroups) == 1 {
 			autoScalingGroup := resDescribeAutoScalingGroups.AutoScalingGroups[0]
 			c.AutoScalingGroup = &AutoScalingGroupDetails{
-				Name:                 autoScalingGroup.AutoScalingGroupName,
-				AutoScalingGroupArn:  autoScalingGroup.AutoScalingGroupARN,
-				DesiredInstanceCount: autoScalingGroup.DesiredCapacity,
-				MaxInstanceCount:     autoScalingGroup.MaxSize,
-				MinInstanceCount:     autoScalingGroup.MinSize,
+				Name:                    autoScalingGroup.AutoScalingGroupName,
+				AutoScalingGroupArn:     autoScalingGroup.AutoScalingGroupARN,
+				DesiredInstanceCount:    autoScalingGroup.DesiredCapacity,
+				MaxInstanceCount:        autoScalingGroup.MaxSize,
+				MinInstanceCount:        autoScalingGroup.MinSize,
+				LaunchTemplate:          launchTemplateOf(autoScalingGroup),
+				LaunchConfigurationName: autoScalingGroup.LaunchConfigurationName,
 			}
 		} else {
 			logrus.Error("Could not find autoscaling group")
@@ -289,21 +439,26 @@ func (c *ClusterDetails) GetContainerInstance(containerInstanceArn *string) *Con
 }
 
 
-func (c *ClusterDetails) IncreaseClusterCapacity() error {
-	newDesiredCapacity := *c.AutoScalingGroup.DesiredInstanceCount + 1
-
+//IncreaseClusterCapacity adds count instances to the cluster's Auto Scaling
+//group, as many as fit when count would exceed its maximum
+func (c *ClusterDetails) IncreaseClusterCapacity(count int64) error {
+	newDesiredCapacity := *c.AutoScalingGroup.DesiredInstanceCount + count
 	if newDesiredCapacity > *c.AutoScalingGroup.MaxInstanceCount {
-		logrus.Error("Maximum Instance Capacity exceeded")
-		return nil
+		newDesiredCapacity = *c.AutoScalingGroup.MaxInstanceCount
+	}
+
+	if newDesiredCapacity <= *c.AutoScalingGroup.DesiredInstanceCount {
+		return errors.Errorf("Auto Scaling group %s has no room for %d more instances, it is at its maximum of %d", *c.AutoScalingGroup.Name, count, *c.AutoScalingGroup.MaxInstanceCount)
 	}
 
 	req := &autoscaling.UpdateAutoScalingGroupInput{DesiredCapacity: &newDesiredCapacity, AutoScalingGroupName: c.AutoScalingGroup.Name}
 	logrus.WithFields(logrus.Fields{
+		"AccountId":            c.AccountId,
 		"AutoScalingGroupName": *req.AutoScalingGroupName,
 		"DesiredCapacity":      *req.DesiredCapacity,
 	}).Info("Increasing Cluster Capacity")
 
-	_, err := autoscalingService.UpdateAutoScalingGroup(req)
+	_, err := c.client.autoscalingService.UpdateAutoScalingGroup(req)
 
 	if err != nil {
 		logrus.Error(err)
@@ -314,53 +469,69 @@ func (c *ClusterDetails) IncreaseClusterCapacity() error {
 }
 
 
-func (c *ClusterDetails) StandByClusterInstance(containerInstanceArn *string) (*string, error) {
-
-	var containerInstance = c.GetContainerInstance(containerInstanceArn)
-
-	logrus.WithFields(logrus.Fields{
-		"ClusterArn":           *c.ClusterArn,
-		"ContainerInstanceARN": *containerInstanceArn,
-	}).Info("Placing Instance in Standby")
+//SetClusterCapacity changes the minimum, maximum and desired size of the
+//cluster's Auto Scaling group, nil sizes are left unchanged
+func (c *ClusterDetails) SetClusterCapacity(min *int64, max *int64, desired *int64) error {
+	req := &autoscaling.UpdateAutoScalingGroupInput{AutoScalingGroupName: c.AutoScalingGroup.Name, MinSize: min, MaxSize: max, DesiredCapacity: desired}
+	fields := logrus.Fields{
+		"AccountId":            c.AccountId,
+		"AutoScalingGroupName": *req.AutoScalingGroupName,
+	}
+	if min != nil {
+		fields["MinSize"] = *min
+	}
+	if max != nil {
+		fields["MaxSize"] = *max
+	}
+	if desired != nil {
+		fields["DesiredCapacity"] = *desired
+	}
+	logrus.WithFields(fields).Info("Setting Cluster Capacity")
 
-	var shouldDecrement = false
-	_, err := autoscalingService.EnterStandby(&autoscaling.EnterStandbyInput{AutoScalingGroupName: c.AutoScalingGroup.Name, InstanceIds: []*string{containerInstance.EC2InstanceId}, ShouldDecrementDesiredCapacity: &shouldDecrement})
+	_, err := c.client.autoscalingService.UpdateAutoScalingGroup(req)
 
 	if err != nil {
 		logrus.Error(err)
-		return nil, errors.Wrap(err, 1)
+		return errors.Wrap(err, 1)
 	}
 
-	return containerInstanceArn, nil
+	return nil
 }
-
-func (c *ClusterDetails) DrainClusterInstance(containerInstanceArn *string) (*string, error) {
-	var drainCandidateContainerInstanceArn *string
-	instanceStillActive := false
-	var instance *ContainerInstance
-	for _, instanceMember := range c.ContainerInstances {
-		if instance == nil {
-			instance = instanceMember
-		} else if *instanceMember.RunningTasksCount < *instance.RunningTasksCount {
-			instance = instanceMember
-		}
-		if containerInstanceArn != nil && *instanceMember.ContainerInstanceArn == *containerInstanceArn {
-			instanceStillActive = true
+//DrainCandidates puts the given instance first, then the least busy ones
+func (c *ClusterDetails) DrainCandidates(containerInstanceArn *string) []*ContainerInstance {
+	candidates := make([]*ContainerInstance, len(c.ContainerInstances))
+	copy(candidates, c.ContainerInstances)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if containerInstanceArn != nil && *candidates[i].ContainerInstanceArn != *candidates[j].ContainerInstanceArn {
+			if *candidates[i].ContainerInstanceArn == *containerInstanceArn {
+				return true
+			}
+			if *candidates[j].ContainerInstanceArn == *containerInstanceArn {
+				return false
+			}
 		}
-	}
-	drainCandidateContainerInstanceArn = instance.ContainerInstanceArn
+		return *candidates[i].RunningTasksCount < *candidates[j].RunningTasksCount
+	})
+	return candidates
+}
 
-	if !instanceStillActive {
-		containerInstanceArn = drainCandidateContainerInstanceArn
-	}
+//DrainCandidate returns the instance DrainClusterInstance would drain: the given
+//instance if it is still in the cluster, otherwise the one running the fewest tasks
+func (c *ClusterDetails) DrainCandidate(containerInstanceArn *string) *string {
+	return c.DrainCandidates(containerInstanceArn)[0].ContainerInstanceArn
+}
+
+func (c *ClusterDetails) DrainClusterInstance(containerInstanceArn *string) (*string, error) {
+	containerInstanceArn = c.DrainCandidate(containerInstanceArn)
 
 	logrus.WithFields(logrus.Fields{
 		"ClusterArn":           *c.ClusterArn,
+		"AccountId":            c.AccountId,
 		"ContainerInstanceARN": *containerInstanceArn,
 	}).Info("Draining Cluster Instance")
 
 	instanceState := "DRAINING"
-	_, err := ecsService.UpdateContainerInstancesState(&ecs.UpdateContainerInstancesStateInput{ContainerInstances: []*string{containerInstanceArn}, Status: &instanceState, Cluster: c.ClusterArn})
+	_, err := c.client.ecsService.UpdateContainerInstancesState(&ecs.UpdateContainerInstancesStateInput{ContainerInstances: []*string{containerInstanceArn}, Status: &instanceState, Cluster: c.ClusterArn})
 
 	if err != nil {
 		logrus.Error(err)
@@ -374,18 +545,19 @@ func (c *ClusterDetails) RemoveClusterInstance(containerInstanceArn *string) err
 	instance := c.GetContainerInstance(containerInstanceArn)
 	logrus.WithFields(logrus.Fields{
 		"ClusterArn": *c.ClusterArn,
+		"AccountId":  c.AccountId,
 		"InstanceId": *instance.EC2InstanceId,
 	}).Info("Removing Cluster Instance")
 
 	trueAddress := true
-	_, err := autoscalingService.DetachInstances(&autoscaling.DetachInstancesInput{AutoScalingGroupName: c.AutoScalingGroup.Name, InstanceIds: []*string{instance.EC2InstanceId}, ShouldDecrementDesiredCapacity: &trueAddress})
+	_, err := c.client.autoscalingService.DetachInstances(&autoscaling.DetachInstancesInput{AutoScalingGroupName: c.AutoScalingGroup.Name, InstanceIds: []*string{instance.EC2InstanceId}, ShouldDecrementDesiredCapacity: &trueAddress})
 
 	if err != nil {
 		logrus.Error(err)
 		return errors.Wrap(err, 1)
 	}
 
-	_, terminateErr := ec2Service.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{instance.EC2InstanceId}})
+	_, terminateErr := c.client.ec2Service.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{instance.EC2InstanceId}})
 	if terminateErr != nil {
 		logrus.Error(terminateErr)
 		return errors.Wrap(terminateErr, 1)
@@ -394,51 +566,182 @@ func (c *ClusterDetails) RemoveClusterInstance(containerInstanceArn *string) err
 	return nil
 }
 
-//GetClusters returns the clusters in the current account
-func GetClusters() ([]*ClusterDetails, error) {
-	var clusters []*ClusterDetails
+//describeCluster loads the instances, services, tasks and auto scaling group
+//of a described cluster
+func (client *Client) describeCluster(clusterRes *ecs.Cluster) (*ClusterDetails, error) {
+	var cluster ClusterDetails
+	cluster.client = client
+	cluster.ClusterArn = clusterRes.ClusterArn
+	cluster.ClusterName = clusterRes.ClusterName
+	cluster.AccountId = accountIdFromArn(*clusterRes.ClusterArn)
+	cluster.Region = client.region
+	cluster.TotalPendingTasks = clusterRes.PendingTasksCount
+	cluster.TotalRunningTasks = clusterRes.RunningTasksCount
+	err := cluster.getContainerInstances()
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+	for _, containerInstance := range cluster.ContainerInstances {
+		cluster.TotalCPU = *containerInstance.TotalCPU + cluster.TotalCPU
+		cluster.TotalMemory = *containerInstance.TotalMemory + cluster.TotalMemory
+		cluster.TotalRemainingCPU = *containerInstance.RemainingCPU + cluster.TotalRemainingCPU
+		cluster.TotalRemainingMemory = *containerInstance.RemainingMemory + cluster.TotalRemainingMemory
+	}
 
-	res, err := ecsService.ListClusters(nil)
+	err = cluster.getServices()
 	if err != nil {
 		return nil, errors.Wrap(err, 1)
 	}
 
-	reqDescribeClusters := ecs.DescribeClustersInput{Clusters: res.ClusterArns}
-	resCluster, err := ecsService.DescribeClusters(&reqDescribeClusters)
+	err = cluster.getTasks()
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
 
-	for _, clusterRes := range resCluster.Clusters {
-		var cluster ClusterDetails
-		cluster.ClusterArn = clusterRes.ClusterArn
-		cluster.TotalPendingTasks = clusterRes.PendingTasksCount
-		cluster.TotalRunningTasks = clusterRes.RunningTasksCount
-		err := cluster.getContainerInstances()
-		if err != nil {
-			return nil, errors.Wrap(err, 1)
-		}
-		for _, containerInstance := range cluster.ContainerInstances {
-			cluster.TotalCPU = *containerInstance.TotalCPU + cluster.TotalCPU
-			cluster.TotalMemory = *containerInstance.TotalMemory + cluster.TotalMemory
-			cluster.TotalRemainingCPU = *containerInstance.RemainingCPU + cluster.TotalRemainingCPU
-			cluster.TotalRemainingMemory = *containerInstance.RemainingMemory + cluster.TotalRemainingMemory
+	err = cluster.getAutoScalingGroups()
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+	return &cluster, nil
+}
+
+//SetClusterFilter limits GetClusters to the clusters selected by filter in every account and region
+func SetClusterFilter(filter *ClusterFilter) {
+	for _, client := range defaultClients {
+		client.SetClusterFilter(filter)
+	}
+}
+
+//ClusterError is a cluster that could not be described, or every cluster of
+//a region when ClusterArn is empty
+type ClusterError struct {
+	ClusterArn string
+	Region     string
+	Err        error
+}
+
+func (e *ClusterError) Error() string {
+	if e.ClusterArn == "" {
+		return fmt.Sprintf("listing clusters in %s: %s", e.Region, e.Err)
+	}
+	return fmt.Sprintf("describing %s: %s", e.ClusterArn, e.Err)
+}
+
+//ClusterErrors lists the clusters GetClusters could not describe, it is
+//returned along with the clusters that were described
+type ClusterErrors []*ClusterError
+
+func (e ClusterErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, clusterErr := range e {
+		messages = append(messages, clusterErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+//GetClusters returns every account's clusters, failures as ClusterErrors
+func GetClusters() ([]*ClusterDetails, error) {
+	clusters := make([]*ClusterDetails, 0)
+	failures := make(ClusterErrors, 0)
+	for _, client := range defaultClients {
+		regionClusters, err := client.GetClusters()
+		clusters = append(clusters, regionClusters...)
+		if clusterErrs, ok := err.(ClusterErrors); ok {
+			failures = append(failures, clusterErrs...)
+		} else if err != nil {
+			failures = append(failures, &ClusterError{Region: client.region, Err: err})
 		}
+	}
+	if len(failures) > 0 {
+		return clusters, failures
+	}
+	return clusters, nil
+}
 
-		err = cluster.getServices()
+//SetClients replaces the clients GetClusters reads from, e.g. with a client
+//from NewFakeClient so a whole check pass runs without AWS
+func SetClients(clients ...*Client) {
+	defaultClients = clients
+}
+
+//GetClusters describes the client's clusters that pass its filter
+func (client *Client) GetClusters() ([]*ClusterDetails, error) {
+	var clusters []*ClusterDetails
+
+	clusterArns := make([]*string, 0)
+	req := ecs.ListClustersInput{}
+	for {
+		var res *ecs.ListClustersOutput
+		err := retry("ListClusters", func() (err error) {
+			res, err = client.ecsService.ListClusters(&req)
+			return err
+		})
 		if err != nil {
 			return nil, errors.Wrap(err, 1)
 		}
 
-		err = cluster.getTasks()
-		if err != nil {
-			return nil, errors.Wrap(err, 1)
+		for _, clusterArn := range res.ClusterArns {
+			if !client.filter.MatchesName(clusterNameFromArn(*clusterArn), *clusterArn) {
+				logrus.WithFields(logrus.Fields{
+					"ClusterArn": *clusterArn,
+				}).Debug("Skipping cluster excluded by name")
+				continue
+			}
+			clusterArns = append(clusterArns, clusterArn)
 		}
+		if res.NextToken == nil {
+			break
+		}
+		req.NextToken = res.NextToken
+	}
 
-		err = cluster.getAutoScalingGroups()
+	describedClusters := make([]*ecs.Cluster, 0)
+	for _, clusterArnsChunk := range chunk(clusterArns, describeClustersLimit) {
+		reqDescribeClusters := ecs.DescribeClustersInput{Clusters: clusterArnsChunk}
+		if client.filter.needsTags() {
+			reqDescribeClusters.Include = []*string{aws.String(ecs.ClusterFieldTags)}
+		}
+		var resCluster *ecs.DescribeClustersOutput
+		err := retry("DescribeClusters", func() (err error) {
+			resCluster, err = client.ecsService.DescribeClusters(&reqDescribeClusters)
+			return err
+		})
 		if err != nil {
 			return nil, errors.Wrap(err, 1)
 		}
+		for _, clusterRes := range resCluster.Clusters {
+			if !client.filter.MatchesTags(clusterRes.Tags) {
+				logrus.WithFields(logrus.Fields{
+					"ClusterArn": *clusterRes.ClusterArn,
+				}).Debug("Skipping cluster without opt-in tags")
+				continue
+			}
+			describedClusters = append(describedClusters, clusterRes)
+		}
+	}
 
-		clusters = append(clusters, &cluster)
+	failures := make(ClusterErrors, 0)
+	for _, clusterRes := range describedClusters {
+		var cluster *ClusterDetails
+		err := retry("DescribeCluster", func() (err error) {
+			cluster, err = client.describeCluster(clusterRes)
+			return err
+		})
+		if err != nil && Classify(err) == ErrorNotFound {
+			logrus.WithFields(logrus.Fields{
+				"ClusterArn": *clusterRes.ClusterArn,
+			}).Warn(err)
+			continue
+		}
+		if err != nil {
+			failures = append(failures, &ClusterError{ClusterArn: *clusterRes.ClusterArn, Region: client.region, Err: err})
+			continue
+		}
+		clusters = append(clusters, cluster)
 	}
 
+	if len(failures) > 0 {
+		return clusters, failures
+	}
 	return clusters, nil
 }
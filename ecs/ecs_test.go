@@ -0,0 +1,112 @@
+package ecs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func countCalls(fake *FakeAWS, operation string) int {
+	count := 0
+	for _, call := range fake.Calls {
+		if call == operation {
+			count++
+		}
+	}
+	return count
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		items int
+		size  int
+		want  []int
+	}{
+		{0, 10, []int{}},
+		{3, 10, []int{3}},
+		{10, 10, []int{10}},
+		{11, 10, []int{10, 1}},
+		{25, 10, []int{10, 10, 5}},
+	}
+	for _, test := range tests {
+		items := make([]*string, test.items)
+		for i := range items {
+			items[i] = aws.String(fmt.Sprint(i))
+		}
+		chunks := chunk(items, test.size)
+		if len(chunks) != len(test.want) {
+			t.Fatalf("chunk(%d items, %d) = %d chunks, want %d", test.items, test.size, len(chunks), len(test.want))
+		}
+		next := 0
+		for i, items := range chunks {
+			if len(items) != test.want[i] {
+				t.Fatalf("chunk(%d items, %d)[%d] has %d items, want %d", test.items, test.size, i, len(items), test.want[i])
+			}
+			for _, item := range items {
+				if *item != fmt.Sprint(next) {
+					t.Fatalf("chunk(%d items, %d) gives %s at %d", test.items, test.size, *item, next)
+				}
+				next++
+			}
+		}
+	}
+}
+
+func TestGetClustersReadsEveryPage(t *testing.T) {
+	fake := NewFakeAWS()
+	fake.PageSize = 3
+	for _, name := range []string{"api", "batch", "jobs", "web"} {
+		fake.AddCluster(name)
+	}
+	clusterArn := fakeAccountPrefix + "cluster/web"
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 20, 12, 4096, 8192)
+	for i := 0; i < 25; i++ {
+		fake.AddService(clusterArn, fmt.Sprintf("service-%d", i), 1, 128, 256)
+	}
+
+	clusters, err := NewFakeClient(fake).GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 4 {
+		t.Fatalf("got %d clusters, want 4", len(clusters))
+	}
+	for _, cluster := range clusters {
+		if *cluster.ClusterArn != clusterArn {
+			continue
+		}
+		if len(cluster.ContainerInstances) != 12 || len(cluster.Tasks) != 25 || len(cluster.Services) != 25 {
+			t.Fatalf("described %d instances, %d tasks and %d services, want 12, 25 and 25", len(cluster.ContainerInstances), len(cluster.Tasks), len(cluster.Services))
+		}
+	}
+	//25 services are listed 3 at a time and described 10 at a time
+	if calls := countCalls(fake, "DescribeServices"); calls != 3 {
+		t.Fatalf("DescribeServices called %d times, want 3", calls)
+	}
+	if calls := countCalls(fake, "ListClusters"); calls != 2 {
+		t.Fatalf("ListClusters called %d times, want 2", calls)
+	}
+}
+
+func TestIncreaseClusterCapacityStopsAtMaximum(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 3, 2, 1024, 2048)
+
+	//as many as fit under the maximum
+	if err := describeFake(t, fake).IncreaseClusterCapacity(5); err != nil {
+		t.Fatal(err)
+	}
+	if desired := *group.Group.DesiredCapacity; desired != 3 {
+		t.Fatalf("desired capacity = %d, want the maximum of 3", desired)
+	}
+
+	//none fit once at the maximum
+	if err := describeFake(t, fake).IncreaseClusterCapacity(1); err == nil {
+		t.Fatal("increase at the maximum succeeded, want an error")
+	}
+	if calls := countCalls(fake, "UpdateAutoScalingGroup"); calls != 1 {
+		t.Fatalf("UpdateAutoScalingGroup called %d times, want 1", calls)
+	}
+}
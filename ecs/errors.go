@@ -0,0 +1,169 @@
+package ecs
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-errors/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorClass groups AWS errors by how the manager should react to them
+type ErrorClass int
+
+const (
+	// ErrorOther is an error retrying will not fix, such as a validation error
+	ErrorOther ErrorClass = iota
+	// ErrorThrottling means AWS rejected the call for exceeding a rate limit
+	ErrorThrottling
+	// ErrorTransient is a timeout, connection failure or AWS server error
+	ErrorTransient
+	// ErrorAuth means the credentials are missing, expired or not allowed
+	ErrorAuth
+	// ErrorNotFound means the cluster, instance or group no longer exists
+	ErrorNotFound
+)
+
+var authCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"AuthFailure":                 true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"InvalidClientTokenId":        true,
+	"MissingAuthenticationToken":  true,
+	"NoCredentialProviders":       true,
+	"SignatureDoesNotMatch":       true,
+	"UnauthorizedOperation":       true,
+	"UnrecognizedClientException": true,
+}
+
+var notFoundCodes = map[string]bool{
+	"ClusterNotFoundException":   true,
+	"ServiceNotFoundException":   true,
+	"ResourceNotFoundException":  true,
+	"InvalidInstanceID.NotFound": true,
+}
+
+var transientCodes = map[string]bool{
+	"ServerException":             true,
+	"InternalFailure":             true,
+	"InternalError":               true,
+	"ServiceUnavailable":          true,
+	"ServiceUnavailableException": true,
+}
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorOther:
+		return "Other"
+	case ErrorThrottling:
+		return "Throttling"
+	case ErrorTransient:
+		return "Transient"
+	case ErrorAuth:
+		return "Auth"
+	case ErrorNotFound:
+		return "NotFound"
+	}
+	return "?"
+}
+
+// Retriable reports whether the same call may succeed if made again
+func (c ErrorClass) Retriable() bool {
+	return c == ErrorThrottling || c == ErrorTransient
+}
+
+// Classify returns the class of an error returned by this package or the
+// AWS sdk, looking through errors wrapped with go-errors
+func Classify(err error) ErrorClass {
+	for {
+		wrapped, ok := err.(*errors.Error)
+		if !ok {
+			break
+		}
+		err = wrapped.Err
+	}
+
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return ErrorOther
+	}
+	code := aerr.Code()
+	switch {
+	case request.IsErrorThrottle(err):
+		return ErrorThrottling
+	case authCodes[code]:
+		return ErrorAuth
+	case notFoundCodes[code]:
+		return ErrorNotFound
+	//ECS and Auto Scaling report missing resources as generic client errors
+	case (code == "ClientException" || code == "ValidationError") && strings.Contains(strings.ToLower(aerr.Message()), "not found"):
+		return ErrorNotFound
+	case transientCodes[code] || request.IsErrorRetryable(err):
+		return ErrorTransient
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() >= 500 {
+		return ErrorTransient
+	}
+	return ErrorOther
+}
+
+// RetryPolicy bounds the retries of calls failing with a retriable error
+type RetryPolicy struct {
+	// MaxAttempts includes the first call
+	MaxAttempts int
+	// BaseDelay is the longest wait before the first retry, doubled after each
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between two attempts
+	MaxDelay time.Duration
+}
+
+// retryPolicy is used by every describe call, see SetRetryPolicy
+var retryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// SetRetryPolicy changes how describe calls are retried
+func SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	retryPolicy = policy
+}
+
+// sleep waits between attempts
+var sleep = time.Sleep
+
+// retry calls fn with exponential backoff and full jitter
+func retry(operation string, fn func() error) error {
+	ceiling := retryPolicy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		class := Classify(err)
+		if !class.Retriable() || attempt >= retryPolicy.MaxAttempts {
+			return err
+		}
+
+		delay := time.Duration(0)
+		if ceiling > 0 {
+			delay = time.Duration(rand.Int63n(int64(ceiling)))
+		}
+		logrus.WithFields(logrus.Fields{
+			"Operation": operation,
+			"Class":     class,
+			"Attempt":   attempt,
+			"Delay":     delay,
+		}).Warn(err)
+		sleep(delay)
+
+		ceiling *= 2
+		if ceiling > retryPolicy.MaxDelay {
+			ceiling = retryPolicy.MaxDelay
+		}
+	}
+}
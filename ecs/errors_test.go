@@ -0,0 +1,84 @@
+package ecs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/go-errors/errors"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"plain error", fmt.Errorf("boom"), ErrorOther},
+		{"throttling", awserr.New("ThrottlingException", "Rate exceeded", nil), ErrorThrottling},
+		{"auto scaling throttling", awserr.New("Throttling", "Rate exceeded", nil), ErrorThrottling},
+		{"request limit", awserr.NewRequestFailure(awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil), 503, "1"), ErrorThrottling},
+		{"wrapped throttling", errors.Wrap(errors.Wrap(awserr.New("ThrottlingException", "Rate exceeded", nil), 0), 0), ErrorThrottling},
+		{"server exception", awserr.New("ServerException", "Service unavailable", nil), ErrorTransient},
+		{"5xx", awserr.NewRequestFailure(awserr.New("SomethingBroke", "oops", nil), 502, "1"), ErrorTransient},
+		{"expired token", awserr.New("ExpiredTokenException", "The security token included in the request is expired", nil), ErrorAuth},
+		{"access denied", awserr.NewRequestFailure(awserr.New("AccessDeniedException", "not authorized", nil), 400, "1"), ErrorAuth},
+		{"missing cluster", awserr.New("ClusterNotFoundException", "Cluster not found.", nil), ErrorNotFound},
+		{"missing group", awserr.New("ValidationError", "AutoScalingGroup name not found - web-asg", nil), ErrorNotFound},
+		{"validation", awserr.New("ValidationError", "New SetDesiredCapacity value 9 is above max value 5", nil), ErrorOther},
+		{"4xx", awserr.NewRequestFailure(awserr.New("InvalidParameterException", "bad", nil), 400, "1"), ErrorOther},
+	}
+	for _, test := range tests {
+		if got := Classify(test.err); got != test.want {
+			t.Errorf("%s: Classify = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	denied := awserr.New("AccessDeniedException", "not authorized", nil)
+
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		fails    bool
+	}{
+		{"succeeds first", []error{nil}, 1, false},
+		{"throttled then succeeds", []error{throttled, throttled, nil}, 3, false},
+		{"throttled throughout", []error{throttled, throttled, throttled, nil}, 3, true},
+		{"fatal stops at once", []error{denied, nil}, 1, true},
+	}
+
+	defer SetRetryPolicy(retryPolicy)
+	defer func(original func(time.Duration)) { sleep = original }(sleep)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 150 * time.Millisecond})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delays := make([]time.Duration, 0)
+			sleep = func(delay time.Duration) { delays = append(delays, delay) }
+
+			attempts := 0
+			err := retry("DescribeClusters", func() error {
+				attempts++
+				return test.errs[attempts-1]
+			})
+			if attempts != test.attempts || (err != nil) != test.fails {
+				t.Fatalf("%d attempts, error %v, want %d attempts and failure %t", attempts, err, test.attempts, test.fails)
+			}
+			if len(delays) != attempts-1 {
+				t.Fatalf("slept %d times in %d attempts", len(delays), attempts)
+			}
+			//full jitter under a ceiling doubled after each retry and capped
+			for i, delay := range delays {
+				ceiling := []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}[i]
+				if delay < 0 || delay >= ceiling {
+					t.Fatalf("delay %d = %s, want under %s", i, delay, ceiling)
+				}
+			}
+		})
+	}
+}
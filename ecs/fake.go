@@ -0,0 +1,1030 @@
+package ecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const fakeAccountPrefix = "arn:aws:ecs:us-west-2:123456789012:"
+
+// FakeAWS is an in-memory ECS, Auto Scaling and EC2 that keeps them consistent
+type FakeAWS struct {
+	Clusters              []*FakeCluster
+	AutoScalingGroups     []*FakeAutoScalingGroup
+	TerminatedInstanceIds []string
+	// Calls records the name of every API operation invoked, in order
+	Calls []string
+	// Now is used for every timestamp the fake produces, defaults to time.Now
+	Now func() time.Time
+	// PageSize caps the number of results returned by a single List call,
+	// defaults to 100 like the ECS API
+	PageSize int
+	// Errors queues the errors returned by the next calls of each operation,
+	// see FailNext
+	Errors map[string][]error
+	// Parameters holds the values returned by GetParameter, by name
+	Parameters map[string]string
+
+	mutex    sync.Mutex
+	sequence int
+	// images holds the AMI each launched instance started from, by instance id
+	images map[string]string
+}
+
+// FakeCluster is the state of one cluster held by FakeAWS
+type FakeCluster struct {
+	Cluster            *ecs.Cluster
+	ContainerInstances []*ecs.ContainerInstance
+	Tasks              []*ecs.Task
+	Services           []*ecs.Service
+	serviceTasks       map[string]*fakeTaskDefinition
+}
+
+// FakeAutoScalingGroup launches instances into ClusterArn with the given resources
+type FakeAutoScalingGroup struct {
+	Group             *autoscaling.Group
+	ClusterArn        string
+	InstanceCPU       int64
+	InstanceMemory    int64
+	AvailabilityZones []string
+	// ImageId is the AMI of the group's launch configuration, changing it
+	// only affects instances launched afterwards
+	ImageId string
+}
+
+type fakeTaskDefinition struct {
+	cpu    int64
+	memory int64
+}
+
+// NewFakeAWS returns an empty FakeAWS
+func NewFakeAWS() *FakeAWS {
+	return &FakeAWS{
+		Clusters:              make([]*FakeCluster, 0),
+		AutoScalingGroups:     make([]*FakeAutoScalingGroup, 0),
+		TerminatedInstanceIds: make([]string, 0),
+		Calls:                 make([]string, 0),
+	}
+}
+
+// NewFakeClient returns a Client backed by the given FakeAWS
+func NewFakeClient(fake *FakeAWS) *Client {
+	return NewClient(fake, fake, fake, fake)
+}
+
+func (f *FakeAWS) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+	return time.Now()
+}
+
+func (f *FakeAWS) nextId() int {
+	f.sequence++
+	return f.sequence
+}
+
+// record notes a call and returns the next error queued for the operation
+func (f *FakeAWS) record(operation string) error {
+	f.Calls = append(f.Calls, operation)
+	if len(f.Errors[operation]) == 0 {
+		return nil
+	}
+	err := f.Errors[operation][0]
+	f.Errors[operation] = f.Errors[operation][1:]
+	return err
+}
+
+// FailNext makes the next calls of operation return errs, one per call
+func (f *FakeAWS) FailNext(operation string, errs ...error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.Errors == nil {
+		f.Errors = make(map[string][]error)
+	}
+	f.Errors[operation] = append(f.Errors[operation], errs...)
+}
+
+// AddCluster creates an empty cluster with the given name
+func (f *FakeAWS) AddCluster(name string) *FakeCluster {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster := &FakeCluster{
+		Cluster: &ecs.Cluster{
+			ClusterArn:  aws.String(fakeAccountPrefix + "cluster/" + name),
+			ClusterName: aws.String(name),
+			Status:      aws.String("ACTIVE"),
+		},
+		ContainerInstances: make([]*ecs.ContainerInstance, 0),
+		Tasks:              make([]*ecs.Task, 0),
+		Services:           make([]*ecs.Service, 0),
+		serviceTasks:       make(map[string]*fakeTaskDefinition),
+	}
+	f.Clusters = append(f.Clusters, cluster)
+	f.refresh()
+	return cluster
+}
+
+// AddAutoScalingGroup creates an auto scaling group whose instances join the
+// given cluster, and launches desired instances into it
+func (f *FakeAWS) AddAutoScalingGroup(name string, clusterArn string, min int64, max int64, desired int64, instanceCPU int64, instanceMemory int64, availabilityZones ...string) *FakeAutoScalingGroup {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(availabilityZones) == 0 {
+		availabilityZones = []string{"us-west-2a"}
+	}
+	group := &FakeAutoScalingGroup{
+		Group: &autoscaling.Group{
+			AutoScalingGroupName:    aws.String(name),
+			AutoScalingGroupARN:     aws.String("arn:aws:autoscaling:us-west-2:123456789012:autoScalingGroup:" + name),
+			MinSize:                 aws.Int64(min),
+			MaxSize:                 aws.Int64(max),
+			DesiredCapacity:         aws.Int64(desired),
+			Instances:               make([]*autoscaling.Instance, 0),
+			LaunchConfigurationName: aws.String(name),
+		},
+		ClusterArn:        clusterArn,
+		InstanceCPU:       instanceCPU,
+		InstanceMemory:    instanceMemory,
+		AvailabilityZones: availabilityZones,
+		ImageId:           "ami-00000001",
+	}
+	f.AutoScalingGroups = append(f.AutoScalingGroups, group)
+	f.refresh()
+	return group
+}
+
+// AddService creates a service whose tasks each reserve the given cpu and
+// memory, and places desired tasks for it
+func (f *FakeAWS) AddService(clusterArn string, name string, desired int64, cpu int64, memory int64) *ecs.Service {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster := f.cluster(&clusterArn)
+	service := &ecs.Service{
+		ServiceArn:         aws.String(fakeAccountPrefix + "service/" + name),
+		ServiceName:        aws.String(name),
+		ClusterArn:         aws.String(clusterArn),
+		DesiredCount:       aws.Int64(desired),
+		RunningCount:       aws.Int64(0),
+		PendingCount:       aws.Int64(0),
+		Status:             aws.String("ACTIVE"),
+		Events:             make([]*ecs.ServiceEvent, 0),
+		SchedulingStrategy: aws.String(ecs.SchedulingStrategyReplica),
+	}
+	cluster.Services = append(cluster.Services, service)
+	cluster.serviceTasks[name] = &fakeTaskDefinition{cpu: cpu, memory: memory}
+	f.refresh()
+	return service
+}
+
+// AddDaemonService creates a DAEMON service, running one task reserving the
+// given cpu and memory on every ACTIVE instance
+func (f *FakeAWS) AddDaemonService(clusterArn string, name string, cpu int64, memory int64) *ecs.Service {
+	service := f.AddService(clusterArn, name, 0, cpu, memory)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	service.SchedulingStrategy = aws.String(ecs.SchedulingStrategyDaemon)
+	f.refresh()
+	return service
+}
+
+// AddTask places a standalone task on the given container instance
+func (f *FakeAWS) AddTask(clusterArn string, containerInstanceArn string, cpu int64, memory int64) *ecs.Task {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster := f.cluster(&clusterArn)
+	task := f.newTask(cluster, aws.String(containerInstanceArn), "family:standalone", cpu, memory)
+	f.refresh()
+	return task
+}
+
+// TagCluster sets a tag on a cluster
+func (f *FakeAWS) TagCluster(clusterArn string, key string, value string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cluster := f.cluster(&clusterArn)
+	cluster.Cluster.Tags = append(cluster.Cluster.Tags, &ecs.Tag{Key: aws.String(key), Value: aws.String(value)})
+}
+
+// SetDesiredCount changes the desired task count of a service
+func (f *FakeAWS) SetDesiredCount(clusterArn string, name string, desired int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, service := range f.cluster(&clusterArn).Services {
+		if *service.ServiceName == name {
+			service.DesiredCount = aws.Int64(desired)
+		}
+	}
+	f.refresh()
+}
+
+func (f *FakeAWS) cluster(clusterArn *string) *FakeCluster {
+	for _, cluster := range f.Clusters {
+		if clusterArn != nil && (*cluster.Cluster.ClusterArn == *clusterArn || *cluster.Cluster.ClusterName == *clusterArn) {
+			return cluster
+		}
+	}
+	return nil
+}
+
+func (f *FakeAWS) autoScalingGroup(name *string) *FakeAutoScalingGroup {
+	for _, group := range f.AutoScalingGroups {
+		if name != nil && *group.Group.AutoScalingGroupName == *name {
+			return group
+		}
+	}
+	return nil
+}
+
+func (f *FakeAWS) autoScalingGroupOfInstance(instanceId *string) (*FakeAutoScalingGroup, *autoscaling.Instance) {
+	for _, group := range f.AutoScalingGroups {
+		for _, instance := range group.Group.Instances {
+			if *instance.InstanceId == *instanceId {
+				return group, instance
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (f *FakeAWS) containerInstanceByEC2Id(instanceId *string) (*FakeCluster, *ecs.ContainerInstance) {
+	for _, cluster := range f.Clusters {
+		for _, containerInstance := range cluster.ContainerInstances {
+			if *containerInstance.Ec2InstanceId == *instanceId {
+				return cluster, containerInstance
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (f *FakeAWS) launchInstance(group *FakeAutoScalingGroup) {
+	id := f.nextId()
+	instanceId := fmt.Sprintf("i-%017d", id)
+	zone := group.AvailabilityZones[len(group.Group.Instances)%len(group.AvailabilityZones)]
+	group.Group.Instances = append(group.Group.Instances, &autoscaling.Instance{
+		InstanceId:       aws.String(instanceId),
+		AvailabilityZone: aws.String(zone),
+		LifecycleState:   aws.String(autoscaling.LifecycleStateInService),
+		HealthStatus:     aws.String("Healthy"),
+	})
+	if f.images == nil {
+		f.images = make(map[string]string)
+	}
+	f.images[instanceId] = group.ImageId
+
+	cluster := f.cluster(&group.ClusterArn)
+	if cluster == nil {
+		return
+	}
+	cluster.ContainerInstances = append(cluster.ContainerInstances, &ecs.ContainerInstance{
+		ContainerInstanceArn: aws.String(fmt.Sprintf("%scontainer-instance/%d", fakeAccountPrefix, id)),
+		Ec2InstanceId:        aws.String(instanceId),
+		RegisteredAt:         aws.Time(f.now()),
+		Status:               aws.String("ACTIVE"),
+		AgentConnected:       aws.Bool(true),
+		RegisteredResources:  fakeResources(group.InstanceCPU, group.InstanceMemory),
+		RemainingResources:   fakeResources(group.InstanceCPU, group.InstanceMemory),
+		RunningTasksCount:    aws.Int64(0),
+		PendingTasksCount:    aws.Int64(0),
+		Attributes: []*ecs.Attribute{
+			{Name: aws.String("ecs.availability-zone"), Value: aws.String(zone)},
+		},
+	})
+}
+
+func (f *FakeAWS) terminateInstance(instanceId *string) {
+	for _, group := range f.AutoScalingGroups {
+		instances := make([]*autoscaling.Instance, 0)
+		for _, instance := range group.Group.Instances {
+			if *instance.InstanceId != *instanceId {
+				instances = append(instances, instance)
+			}
+		}
+		group.Group.Instances = instances
+	}
+
+	cluster, containerInstance := f.containerInstanceByEC2Id(instanceId)
+	if cluster != nil {
+		containerInstances := make([]*ecs.ContainerInstance, 0)
+		for _, member := range cluster.ContainerInstances {
+			if member != containerInstance {
+				containerInstances = append(containerInstances, member)
+			}
+		}
+		cluster.ContainerInstances = containerInstances
+		f.stopTasks(cluster, containerInstance.ContainerInstanceArn, true)
+	}
+	f.TerminatedInstanceIds = append(f.TerminatedInstanceIds, *instanceId)
+}
+
+func fakeResources(cpu int64, memory int64) []*ecs.Resource {
+	return []*ecs.Resource{
+		{Name: aws.String("CPU"), Type: aws.String("INTEGER"), IntegerValue: aws.Int64(cpu)},
+		{Name: aws.String("MEMORY"), Type: aws.String("INTEGER"), IntegerValue: aws.Int64(memory)},
+	}
+}
+
+func (f *FakeAWS) newTask(cluster *FakeCluster, containerInstanceArn *string, group string, cpu int64, memory int64) *ecs.Task {
+	task := &ecs.Task{
+		TaskArn:              aws.String(fmt.Sprintf("%stask/%d", fakeAccountPrefix, f.nextId())),
+		ClusterArn:           cluster.Cluster.ClusterArn,
+		ContainerInstanceArn: containerInstanceArn,
+		Group:                aws.String(group),
+		LastStatus:           aws.String("RUNNING"),
+		DesiredStatus:        aws.String("RUNNING"),
+		Cpu:                  aws.String(strconv.FormatInt(cpu, 10)),
+		Memory:               aws.String(strconv.FormatInt(memory, 10)),
+		CreatedAt:            aws.Time(f.now()),
+	}
+	cluster.Tasks = append(cluster.Tasks, task)
+	return task
+}
+
+// stopTasks removes the tasks running on a container instance, only touching
+// standalone and daemon tasks when includeStandalone is set
+func (f *FakeAWS) stopTasks(cluster *FakeCluster, containerInstanceArn *string, includeStandalone bool) {
+	tasks := make([]*ecs.Task, 0)
+	for _, task := range cluster.Tasks {
+		onInstance := task.ContainerInstanceArn != nil && *task.ContainerInstanceArn == *containerInstanceArn
+		isService := strings.HasPrefix(*task.Group, "service:") && !f.isDaemon(cluster, *task.Group)
+		if onInstance && (isService || includeStandalone) {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	cluster.Tasks = tasks
+}
+
+// isDaemon reports whether a task group belongs to a DAEMON service
+func (f *FakeAWS) isDaemon(cluster *FakeCluster, group string) bool {
+	for _, service := range cluster.Services {
+		if "service:"+*service.ServiceName == group {
+			return aws.StringValue(service.SchedulingStrategy) == ecs.SchedulingStrategyDaemon
+		}
+	}
+	return false
+}
+
+// placeDaemonTasks runs a task of a DAEMON service on every ACTIVE instance
+// missing one, leaving the tasks on draining instances in place
+func (f *FakeAWS) placeDaemonTasks(cluster *FakeCluster, service *ecs.Service) {
+	definition := cluster.serviceTasks[*service.ServiceName]
+	group := "service:" + *service.ServiceName
+	running := int64(0)
+	for _, containerInstance := range cluster.ContainerInstances {
+		placed := false
+		for _, task := range cluster.Tasks {
+			if *task.Group == group && task.ContainerInstanceArn != nil && *task.ContainerInstanceArn == *containerInstance.ContainerInstanceArn {
+				placed = true
+			}
+		}
+		if !placed && *containerInstance.Status == "ACTIVE" {
+			f.newTask(cluster, containerInstance.ContainerInstanceArn, group, definition.cpu, definition.memory)
+			placed = true
+		}
+		if placed {
+			running++
+		}
+	}
+	service.DesiredCount = aws.Int64(running)
+	service.RunningCount = aws.Int64(running)
+	service.PendingCount = aws.Int64(0)
+	f.updateRemainingResources(cluster)
+}
+
+func fakeResourceValue(resources []*ecs.Resource, name string) int64 {
+	value := getResourceValue(resources, name)
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// refresh brings auto scaling groups to their desired capacity, places any
+// missing service tasks and recomputes every derived count
+func (f *FakeAWS) refresh() {
+	for _, group := range f.AutoScalingGroups {
+		inService := make([]*autoscaling.Instance, 0)
+		for _, instance := range group.Group.Instances {
+			if *instance.LifecycleState == autoscaling.LifecycleStateInService {
+				inService = append(inService, instance)
+			}
+		}
+		for i := int64(len(inService)); i < *group.Group.DesiredCapacity; i++ {
+			f.launchInstance(group)
+		}
+		for i := int64(len(inService)); i > *group.Group.DesiredCapacity; i-- {
+			f.terminateInstance(inService[i-1].InstanceId)
+		}
+	}
+
+	for _, cluster := range f.Clusters {
+		f.updateRemainingResources(cluster)
+		for _, service := range cluster.Services {
+			if aws.StringValue(service.SchedulingStrategy) == ecs.SchedulingStrategyDaemon {
+				f.placeDaemonTasks(cluster, service)
+				continue
+			}
+			definition := cluster.serviceTasks[*service.ServiceName]
+			group := "service:" + *service.ServiceName
+			running := make([]*ecs.Task, 0)
+			for _, task := range cluster.Tasks {
+				if *task.Group == group {
+					running = append(running, task)
+				}
+			}
+			for int64(len(running)) > *service.DesiredCount {
+				f.stopTask(cluster, running[len(running)-1])
+				running = running[:len(running)-1]
+			}
+			for int64(len(running)) < *service.DesiredCount {
+				instance := f.placeTask(cluster, definition.cpu, definition.memory)
+				if instance == nil {
+					break
+				}
+				running = append(running, f.newTask(cluster, instance.ContainerInstanceArn, group, definition.cpu, definition.memory))
+				f.updateRemainingResources(cluster)
+			}
+			service.RunningCount = aws.Int64(int64(len(running)))
+			service.PendingCount = aws.Int64(0)
+		}
+		f.updateRemainingResources(cluster)
+
+		registered := int64(0)
+		for _, containerInstance := range cluster.ContainerInstances {
+			if *containerInstance.Status != "INACTIVE" {
+				registered++
+			}
+		}
+		cluster.Cluster.RegisteredContainerInstancesCount = aws.Int64(registered)
+		cluster.Cluster.RunningTasksCount = aws.Int64(int64(len(cluster.Tasks)))
+		cluster.Cluster.PendingTasksCount = aws.Int64(0)
+		cluster.Cluster.ActiveServicesCount = aws.Int64(int64(len(cluster.Services)))
+	}
+}
+
+func (f *FakeAWS) stopTask(cluster *FakeCluster, stopped *ecs.Task) {
+	tasks := make([]*ecs.Task, 0)
+	for _, task := range cluster.Tasks {
+		if task != stopped {
+			tasks = append(tasks, task)
+		}
+	}
+	cluster.Tasks = tasks
+	f.updateRemainingResources(cluster)
+}
+
+// placeTask picks the ACTIVE instance with the fewest running tasks that has
+// room for the task, mirroring the default spread placement
+func (f *FakeAWS) placeTask(cluster *FakeCluster, cpu int64, memory int64) *ecs.ContainerInstance {
+	var candidate *ecs.ContainerInstance
+	for _, containerInstance := range cluster.ContainerInstances {
+		if *containerInstance.Status != "ACTIVE" || !*containerInstance.AgentConnected {
+			continue
+		}
+		if fakeResourceValue(containerInstance.RemainingResources, "CPU") < cpu || fakeResourceValue(containerInstance.RemainingResources, "MEMORY") < memory {
+			continue
+		}
+		if candidate == nil || *containerInstance.RunningTasksCount < *candidate.RunningTasksCount {
+			candidate = containerInstance
+		}
+	}
+	return candidate
+}
+
+func (f *FakeAWS) updateRemainingResources(cluster *FakeCluster) {
+	for _, containerInstance := range cluster.ContainerInstances {
+		cpu := fakeResourceValue(containerInstance.RegisteredResources, "CPU")
+		memory := fakeResourceValue(containerInstance.RegisteredResources, "MEMORY")
+		running := int64(0)
+		for _, task := range cluster.Tasks {
+			if task.ContainerInstanceArn != nil && *task.ContainerInstanceArn == *containerInstance.ContainerInstanceArn {
+				taskCPU, _ := strconv.ParseInt(*task.Cpu, 10, 64)
+				taskMemory, _ := strconv.ParseInt(*task.Memory, 10, 64)
+				cpu -= taskCPU
+				memory -= taskMemory
+				running++
+			}
+		}
+		containerInstance.RemainingResources = fakeResources(cpu, memory)
+		containerInstance.RunningTasksCount = aws.Int64(running)
+	}
+}
+
+func fakeNotFound(format string, args ...interface{}) error {
+	return awserr.New("ClientException", fmt.Sprintf(format, args...), nil)
+}
+
+// fakeCheckLimit rejects describe calls with more items than AWS accepts
+func fakeCheckLimit(operation string, count int, limit int) error {
+	if count > limit {
+		return awserr.New("InvalidParameterException", fmt.Sprintf("%s accepts at most %d items, got %d", operation, limit, count), nil)
+	}
+	return nil
+}
+
+// page returns the slice of arns selected by nextToken and maxResults along
+// with the token for the following page
+func (f *FakeAWS) page(arns []*string, maxResults *int64, nextToken *string) ([]*string, *string, error) {
+	size := f.PageSize
+	if size <= 0 {
+		size = 100
+	}
+	if maxResults != nil && int(*maxResults) < size {
+		size = int(*maxResults)
+	}
+
+	start := 0
+	if nextToken != nil {
+		var err error
+		start, err = strconv.Atoi(*nextToken)
+		if err != nil || start < 0 || start > len(arns) {
+			return nil, nil, awserr.New("InvalidParameterException", "Invalid NextToken", nil)
+		}
+	}
+
+	end := start + size
+	if end >= len(arns) {
+		return arns[start:], nil, nil
+	}
+	return arns[start:end], aws.String(strconv.Itoa(end)), nil
+}
+
+func (f *FakeAWS) ListClusters(input *ecs.ListClustersInput) (*ecs.ListClustersOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ListClusters"); err != nil {
+		return nil, err
+	}
+
+	if input == nil {
+		input = &ecs.ListClustersInput{}
+	}
+	arns := make([]*string, 0)
+	for _, cluster := range f.Clusters {
+		arns = append(arns, cluster.Cluster.ClusterArn)
+	}
+	arns, nextToken, err := f.page(arns, input.MaxResults, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ecs.ListClustersOutput{ClusterArns: arns, NextToken: nextToken}, nil
+}
+
+func (f *FakeAWS) DescribeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeClusters"); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckLimit("DescribeClusters", len(input.Clusters), 100); err != nil {
+		return nil, err
+	}
+
+	output := &ecs.DescribeClustersOutput{Clusters: make([]*ecs.Cluster, 0), Failures: make([]*ecs.Failure, 0)}
+	for _, arn := range input.Clusters {
+		cluster := f.cluster(arn)
+		if cluster == nil {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: arn, Reason: aws.String("MISSING")})
+			continue
+		}
+		described := *cluster.Cluster
+		includeTags := false
+		for _, field := range input.Include {
+			includeTags = includeTags || *field == ecs.ClusterFieldTags
+		}
+		if !includeTags {
+			described.Tags = nil
+		}
+		output.Clusters = append(output.Clusters, &described)
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) ListContainerInstances(input *ecs.ListContainerInstancesInput) (*ecs.ListContainerInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ListContainerInstances"); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	arns := make([]*string, 0)
+	for _, containerInstance := range cluster.ContainerInstances {
+		if input.Status == nil || *input.Status == *containerInstance.Status {
+			arns = append(arns, containerInstance.ContainerInstanceArn)
+		}
+	}
+	arns, nextToken, err := f.page(arns, input.MaxResults, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ecs.ListContainerInstancesOutput{ContainerInstanceArns: arns, NextToken: nextToken}, nil
+}
+
+func (f *FakeAWS) DescribeContainerInstances(input *ecs.DescribeContainerInstancesInput) (*ecs.DescribeContainerInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeContainerInstances"); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckLimit("DescribeContainerInstances", len(input.ContainerInstances), 100); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	output := &ecs.DescribeContainerInstancesOutput{ContainerInstances: make([]*ecs.ContainerInstance, 0), Failures: make([]*ecs.Failure, 0)}
+	for _, arn := range input.ContainerInstances {
+		found := false
+		for _, containerInstance := range cluster.ContainerInstances {
+			if *containerInstance.ContainerInstanceArn == *arn {
+				output.ContainerInstances = append(output.ContainerInstances, containerInstance)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: arn, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) ListTasks(input *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ListTasks"); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	arns := make([]*string, 0)
+	for _, task := range cluster.Tasks {
+		if input.ContainerInstance == nil || (task.ContainerInstanceArn != nil && *task.ContainerInstanceArn == *input.ContainerInstance) {
+			arns = append(arns, task.TaskArn)
+		}
+	}
+	arns, nextToken, err := f.page(arns, input.MaxResults, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ecs.ListTasksOutput{TaskArns: arns, NextToken: nextToken}, nil
+}
+
+func (f *FakeAWS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeTasks"); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckLimit("DescribeTasks", len(input.Tasks), 100); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	output := &ecs.DescribeTasksOutput{Tasks: make([]*ecs.Task, 0), Failures: make([]*ecs.Failure, 0)}
+	for _, arn := range input.Tasks {
+		found := false
+		for _, task := range cluster.Tasks {
+			if *task.TaskArn == *arn {
+				output.Tasks = append(output.Tasks, task)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: arn, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) ListServices(input *ecs.ListServicesInput) (*ecs.ListServicesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("ListServices"); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	arns := make([]*string, 0)
+	for _, service := range cluster.Services {
+		arns = append(arns, service.ServiceArn)
+	}
+	arns, nextToken, err := f.page(arns, input.MaxResults, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ecs.ListServicesOutput{ServiceArns: arns, NextToken: nextToken}, nil
+}
+
+func (f *FakeAWS) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeServices"); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckLimit("DescribeServices", len(input.Services), 10); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	output := &ecs.DescribeServicesOutput{Services: make([]*ecs.Service, 0), Failures: make([]*ecs.Failure, 0)}
+	for _, arn := range input.Services {
+		found := false
+		for _, service := range cluster.Services {
+			if *service.ServiceArn == *arn || *service.ServiceName == *arn {
+				output.Services = append(output.Services, service)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: arn, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) UpdateContainerInstancesState(input *ecs.UpdateContainerInstancesStateInput) (*ecs.UpdateContainerInstancesStateOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("UpdateContainerInstancesState"); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	output := &ecs.UpdateContainerInstancesStateOutput{ContainerInstances: make([]*ecs.ContainerInstance, 0), Failures: make([]*ecs.Failure, 0)}
+	for _, arn := range input.ContainerInstances {
+		found := false
+		for _, containerInstance := range cluster.ContainerInstances {
+			if *containerInstance.ContainerInstanceArn == *arn {
+				containerInstance.Status = aws.String(*input.Status)
+				if *input.Status == "DRAINING" {
+					f.stopTasks(cluster, arn, false)
+				}
+				output.ContainerInstances = append(output.ContainerInstances, containerInstance)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: arn, Reason: aws.String("MISSING")})
+		}
+	}
+	f.refresh()
+	return output, nil
+}
+
+func (f *FakeAWS) StopTask(input *ecs.StopTaskInput) (*ecs.StopTaskOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("StopTask"); err != nil {
+		return nil, err
+	}
+
+	cluster := f.cluster(input.Cluster)
+	if cluster == nil {
+		return nil, fakeNotFound("cluster %s not found", aws.StringValue(input.Cluster))
+	}
+	for _, task := range cluster.Tasks {
+		if *task.TaskArn == aws.StringValue(input.Task) {
+			f.stopTask(cluster, task)
+			task.LastStatus = aws.String("STOPPED")
+			task.DesiredStatus = aws.String("STOPPED")
+			task.StoppedReason = input.Reason
+			f.refresh()
+			return &ecs.StopTaskOutput{Task: task}, nil
+		}
+	}
+	return nil, fakeNotFound("task %s not found", aws.StringValue(input.Task))
+}
+
+func (f *FakeAWS) DescribeAutoScalingInstances(input *autoscaling.DescribeAutoScalingInstancesInput) (*autoscaling.DescribeAutoScalingInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeAutoScalingInstances"); err != nil {
+		return nil, err
+	}
+	if err := fakeCheckLimit("DescribeAutoScalingInstances", len(input.InstanceIds), 50); err != nil {
+		return nil, err
+	}
+
+	output := &autoscaling.DescribeAutoScalingInstancesOutput{AutoScalingInstances: make([]*autoscaling.InstanceDetails, 0)}
+	for _, instanceId := range input.InstanceIds {
+		group, instance := f.autoScalingGroupOfInstance(instanceId)
+		if group == nil {
+			continue
+		}
+		output.AutoScalingInstances = append(output.AutoScalingInstances, &autoscaling.InstanceDetails{
+			InstanceId:           instance.InstanceId,
+			AutoScalingGroupName: group.Group.AutoScalingGroupName,
+			AvailabilityZone:     instance.AvailabilityZone,
+			LifecycleState:       instance.LifecycleState,
+			HealthStatus:         instance.HealthStatus,
+		})
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeAutoScalingGroups"); err != nil {
+		return nil, err
+	}
+
+	output := &autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: make([]*autoscaling.Group, 0)}
+	for _, group := range f.AutoScalingGroups {
+		if len(input.AutoScalingGroupNames) == 0 {
+			output.AutoScalingGroups = append(output.AutoScalingGroups, group.Group)
+			continue
+		}
+		for _, name := range input.AutoScalingGroupNames {
+			if *name == *group.Group.AutoScalingGroupName {
+				output.AutoScalingGroups = append(output.AutoScalingGroups, group.Group)
+			}
+		}
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) UpdateAutoScalingGroup(input *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("UpdateAutoScalingGroup"); err != nil {
+		return nil, err
+	}
+
+	group := f.autoScalingGroup(input.AutoScalingGroupName)
+	if group == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", aws.StringValue(input.AutoScalingGroupName)), nil)
+	}
+	min := aws.Int64Value(group.Group.MinSize)
+	max := aws.Int64Value(group.Group.MaxSize)
+	desired := aws.Int64Value(group.Group.DesiredCapacity)
+	if input.MinSize != nil {
+		min = *input.MinSize
+	}
+	if input.MaxSize != nil {
+		max = *input.MaxSize
+	}
+	if input.DesiredCapacity != nil {
+		desired = *input.DesiredCapacity
+	}
+	if desired < min || desired > max {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("Desired capacity:%d must be between the specified min size:%d and max size:%d", desired, min, max), nil)
+	}
+	group.Group.MinSize = aws.Int64(min)
+	group.Group.MaxSize = aws.Int64(max)
+	group.Group.DesiredCapacity = aws.Int64(desired)
+	f.refresh()
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (f *FakeAWS) DetachInstances(input *autoscaling.DetachInstancesInput) (*autoscaling.DetachInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DetachInstances"); err != nil {
+		return nil, err
+	}
+
+	group := f.autoScalingGroup(input.AutoScalingGroupName)
+	if group == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - %s", aws.StringValue(input.AutoScalingGroupName)), nil)
+	}
+	for _, instanceId := range input.InstanceIds {
+		instances := make([]*autoscaling.Instance, 0)
+		for _, instance := range group.Group.Instances {
+			if *instance.InstanceId != *instanceId {
+				instances = append(instances, instance)
+			}
+		}
+		if len(instances) == len(group.Group.Instances) {
+			return nil, awserr.New("ValidationError", fmt.Sprintf("The instance %s is not part of Auto Scaling group %s", *instanceId, *group.Group.AutoScalingGroupName), nil)
+		}
+		group.Group.Instances = instances
+		if aws.BoolValue(input.ShouldDecrementDesiredCapacity) {
+			group.Group.DesiredCapacity = aws.Int64(*group.Group.DesiredCapacity - 1)
+		}
+	}
+	f.refresh()
+	return &autoscaling.DetachInstancesOutput{}, nil
+}
+
+func (f *FakeAWS) TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("TerminateInstances"); err != nil {
+		return nil, err
+	}
+
+	output := &ec2.TerminateInstancesOutput{TerminatingInstances: make([]*ec2.InstanceStateChange, 0)}
+	for _, instanceId := range input.InstanceIds {
+		f.terminateInstance(instanceId)
+		output.TerminatingInstances = append(output.TerminatingInstances, &ec2.InstanceStateChange{
+			InstanceId:    instanceId,
+			CurrentState:  &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown)},
+			PreviousState: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+		})
+	}
+	f.refresh()
+	return output, nil
+}
+
+func (f *FakeAWS) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeInstances"); err != nil {
+		return nil, err
+	}
+
+	reservation := &ec2.Reservation{Instances: make([]*ec2.Instance, 0)}
+	for _, instanceId := range input.InstanceIds {
+		imageId, ok := f.images[*instanceId]
+		if !ok {
+			return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", *instanceId), nil)
+		}
+		reservation.Instances = append(reservation.Instances, &ec2.Instance{InstanceId: instanceId, ImageId: aws.String(imageId)})
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{reservation}}, nil
+}
+
+// DescribeLaunchTemplateVersions always fails, FakeAWS groups use launch configurations
+func (f *FakeAWS) DescribeLaunchTemplateVersions(input *ec2.DescribeLaunchTemplateVersionsInput) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeLaunchTemplateVersions"); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("InvalidLaunchTemplateId.NotFound", "launch templates are not supported by FakeAWS", nil)
+}
+
+func (f *FakeAWS) DescribeLaunchConfigurations(input *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("DescribeLaunchConfigurations"); err != nil {
+		return nil, err
+	}
+
+	output := &autoscaling.DescribeLaunchConfigurationsOutput{LaunchConfigurations: make([]*autoscaling.LaunchConfiguration, 0)}
+	for _, name := range input.LaunchConfigurationNames {
+		if group := f.autoScalingGroup(name); group != nil {
+			output.LaunchConfigurations = append(output.LaunchConfigurations, &autoscaling.LaunchConfiguration{
+				LaunchConfigurationName: name,
+				ImageId:                 aws.String(group.ImageId),
+			})
+		}
+	}
+	return output, nil
+}
+
+func (f *FakeAWS) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.record("GetParameter"); err != nil {
+		return nil, err
+	}
+
+	value, ok := f.Parameters[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("parameter %s not found", aws.StringValue(input.Name)), nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: input.Name, Value: aws.String(value)}}, nil
+}
@@ -0,0 +1,116 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+// describeFake returns the only cluster of fake as GetClusters describes it
+func describeFake(t *testing.T, fake *FakeAWS) *ClusterDetails {
+	t.Helper()
+	clusters, err := NewFakeClient(fake).GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("got %d clusters, want 1", len(clusters))
+	}
+	return clusters[0]
+}
+
+func TestFakeDescribesCluster(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 3, 256, 512)
+
+	cluster := describeFake(t, fake)
+	fake.AddTask(clusterArn, *cluster.ContainerInstances[0].ContainerInstanceArn, 128, 256)
+	cluster = describeFake(t, fake)
+
+	if len(cluster.ContainerInstances) != 2 || len(cluster.Tasks) != 4 || len(cluster.Services) != 1 {
+		t.Fatalf("described %d instances, %d tasks and %d services, want 2, 4 and 1", len(cluster.ContainerInstances), len(cluster.Tasks), len(cluster.Services))
+	}
+	if cluster.TotalCPU != 2048 || cluster.TotalMemory != 4096 {
+		t.Fatalf("total cpu %d memory %d, want 2048 and 4096", cluster.TotalCPU, cluster.TotalMemory)
+	}
+	if cluster.TotalRemainingCPU != 2048-3*256-128 || cluster.TotalRemainingMemory != 4096-3*512-256 {
+		t.Fatalf("remaining cpu %d memory %d, want %d and %d", cluster.TotalRemainingCPU, cluster.TotalRemainingMemory, 2048-3*256-128, 4096-3*512-256)
+	}
+	service := cluster.Services[0]
+	if *service.DesiredTaskCount != 3 || *service.CurrentTaskCount != 3 || *service.PendingTaskCount != 0 {
+		t.Fatalf("service counts %d/%d/%d, want 3 desired and running", *service.DesiredTaskCount, *service.CurrentTaskCount, *service.PendingTaskCount)
+	}
+	group := cluster.AutoScalingGroup
+	if group == nil || *group.Name != "web-asg" || *group.MinInstanceCount != 1 || *group.MaxInstanceCount != 4 || *group.DesiredInstanceCount != 2 {
+		t.Fatalf("auto scaling group = %+v, want web-asg 1/4/2", group)
+	}
+}
+
+func TestFakeFollowsScalingActions(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 2, 256, 512)
+	cluster := describeFake(t, fake)
+
+	//a new desired capacity launches and registers an instance
+	if err := cluster.IncreaseClusterCapacity(1); err != nil {
+		t.Fatal(err)
+	}
+	cluster = describeFake(t, fake)
+	if len(cluster.ContainerInstances) != 3 || *group.Group.DesiredCapacity != 3 {
+		t.Fatalf("%d instances at desired %d, want 3", len(cluster.ContainerInstances), *group.Group.DesiredCapacity)
+	}
+
+	//draining moves the service tasks to the other instances
+	drained := cluster.ContainerInstances[0]
+	standalone := fake.AddTask(clusterArn, *drained.ContainerInstanceArn, 128, 256)
+	if _, err := cluster.DrainClusterInstance(drained.ContainerInstanceArn); err != nil {
+		t.Fatal(err)
+	}
+	cluster = describeFake(t, fake)
+	for _, task := range cluster.Tasks {
+		if *task.ContainerInstanceArn == *drained.ContainerInstanceArn && *task.TaskArn != *standalone.TaskArn {
+			t.Fatalf("service task %s still on the draining instance", *task.TaskArn)
+		}
+	}
+	if status := *cluster.GetContainerInstance(drained.ContainerInstanceArn).Status; status != "DRAINING" {
+		t.Fatalf("drained instance status = %s, want DRAINING", status)
+	}
+
+	//removing detaches, terminates and deregisters it
+	if err := cluster.RemoveClusterInstance(drained.ContainerInstanceArn); err != nil {
+		t.Fatal(err)
+	}
+	cluster = describeFake(t, fake)
+	if len(cluster.ContainerInstances) != 2 || *group.Group.DesiredCapacity != 2 || len(group.Group.Instances) != 2 {
+		t.Fatalf("%d instances, %d in the group at desired %d, want 2", len(cluster.ContainerInstances), len(group.Group.Instances), *group.Group.DesiredCapacity)
+	}
+	if len(fake.TerminatedInstanceIds) != 1 || fake.TerminatedInstanceIds[0] != *drained.EC2InstanceId {
+		t.Fatalf("terminated %v, want %s", fake.TerminatedInstanceIds, *drained.EC2InstanceId)
+	}
+	if len(cluster.Tasks) != 2 {
+		t.Fatalf("%d tasks, want the 2 service tasks", len(cluster.Tasks))
+	}
+}
+
+func TestFakeValidatesAutoScalingGroupSize(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 2, 2, 1024, 2048)
+
+	_, err := fake.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String("web-asg"),
+		DesiredCapacity:      aws.Int64(3),
+	})
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "ValidationError" {
+		t.Fatalf("desired capacity above the maximum = %v, want a ValidationError", err)
+	}
+	if calls := fake.Calls; len(calls) != 1 || calls[0] != "UpdateAutoScalingGroup" {
+		t.Fatalf("calls = %v, want only UpdateAutoScalingGroup", calls)
+	}
+}
@@ -0,0 +1,137 @@
+package ecs
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/go-errors/errors"
+)
+
+// ClusterFilter selects the clusters the manager may describe and change
+type ClusterFilter struct {
+	Include      []string
+	Exclude      []string
+	RequiredTags map[string]string
+
+	include []*clusterPattern
+	exclude []*clusterPattern
+}
+
+type clusterPattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func newClusterPattern(pattern string) (*clusterPattern, error) {
+	if strings.HasPrefix(pattern, "regex:") {
+		regex, err := regexp.Compile(strings.TrimPrefix(pattern, "regex:"))
+		if err != nil {
+			return nil, errors.Wrap(err, 1)
+		}
+		return &clusterPattern{regex: regex}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+	return &clusterPattern{glob: pattern}, nil
+}
+
+func (p *clusterPattern) matches(clusterName string, clusterArn string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(clusterName) || p.regex.MatchString(clusterArn)
+	}
+	for _, candidate := range []string{clusterName, clusterArn} {
+		if matched, _ := path.Match(p.glob, candidate); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClusterFilter compiles the include and exclude patterns
+func NewClusterFilter(include []string, exclude []string, requiredTags map[string]string) (*ClusterFilter, error) {
+	filter := &ClusterFilter{
+		Include:      include,
+		Exclude:      exclude,
+		RequiredTags: requiredTags,
+	}
+	for _, pattern := range include {
+		compiled, err := newClusterPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, compiled)
+	}
+	for _, pattern := range exclude {
+		compiled, err := newClusterPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, compiled)
+	}
+	return filter, nil
+}
+
+// clusterNameFromArn returns the name part of arn:aws:ecs:region:account:cluster/name
+func clusterNameFromArn(clusterArn string) string {
+	return clusterArn[strings.LastIndex(clusterArn, "/")+1:]
+}
+
+// accountIdFromArn returns the account part of arn:aws:ecs:region:account:cluster/name
+func accountIdFromArn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// MatchesName applies the include and exclude patterns
+func (f *ClusterFilter) MatchesName(clusterName string, clusterArn string) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.include) == 0
+	for _, pattern := range f.include {
+		if pattern.matches(clusterName, clusterArn) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range f.exclude {
+		if pattern.matches(clusterName, clusterArn) {
+			return false
+		}
+	}
+	return true
+}
+
+// needsTags reports whether clusters must be described with their tags
+func (f *ClusterFilter) needsTags() bool {
+	return f != nil && len(f.RequiredTags) > 0
+}
+
+// MatchesTags checks the opt-in tags
+func (f *ClusterFilter) MatchesTags(tags []*ecs.Tag) bool {
+	if !f.needsTags() {
+		return true
+	}
+	for key, value := range f.RequiredTags {
+		found := false
+		for _, tag := range tags {
+			if tag.Key != nil && *tag.Key == key && tag.Value != nil && (value == "*" || *tag.Value == value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package ecs
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestClusterFilterMatchesName(t *testing.T) {
+	tests := []struct {
+		include []string
+		exclude []string
+		cluster string
+		want    bool
+	}{
+		{nil, nil, "web", true},
+		{[]string{"web"}, nil, "web", true},
+		{[]string{"web"}, nil, "web-staging", false},
+		{[]string{"web*"}, nil, "web-staging", true},
+		{[]string{fakeAccountPrefix + "cluster/api"}, nil, "api", true},
+		{[]string{"arn:aws:ecs:*:*:cluster/api"}, nil, "api", true},
+		{[]string{"regex:^batch-[0-9]+$"}, nil, "batch-42", true},
+		{[]string{"regex:^batch-[0-9]+$"}, nil, "batch-nightly", false},
+		//a regex is unanchored unless it says otherwise
+		{[]string{"regex:prod"}, nil, "web-prod-1", true},
+		{nil, []string{"*-staging"}, "web-staging", false},
+		{[]string{"web*"}, []string{"*-staging"}, "web-prod", true},
+		//exclude wins over include
+		{[]string{"web*"}, []string{"web-staging"}, "web-staging", false},
+	}
+	for _, test := range tests {
+		filter, err := NewClusterFilter(test.include, test.exclude, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filter.MatchesName(test.cluster, fakeAccountPrefix+"cluster/"+test.cluster); got != test.want {
+			t.Errorf("include %q exclude %q matches %s = %t, want %t", test.include, test.exclude, test.cluster, got, test.want)
+		}
+	}
+
+	var none *ClusterFilter
+	if !none.MatchesName("web", fakeAccountPrefix+"cluster/web") {
+		t.Error("a nil filter rejected a cluster")
+	}
+}
+
+func TestClusterFilterMatchesTags(t *testing.T) {
+	tag := func(key string, value string) *ecs.Tag {
+		return &ecs.Tag{Key: aws.String(key), Value: aws.String(value)}
+	}
+	tests := []struct {
+		required map[string]string
+		tags     []*ecs.Tag
+		want     bool
+	}{
+		{nil, nil, true},
+		{map[string]string{"ecs-manager": "enabled"}, []*ecs.Tag{tag("ecs-manager", "enabled")}, true},
+		{map[string]string{"ecs-manager": "enabled"}, []*ecs.Tag{tag("ecs-manager", "disabled")}, false},
+		{map[string]string{"ecs-manager": "enabled"}, nil, false},
+		{map[string]string{"team": "*"}, []*ecs.Tag{tag("team", "payments")}, true},
+		{map[string]string{"team": "*", "env": "prod"}, []*ecs.Tag{tag("team", "payments")}, false},
+		{map[string]string{"team": "*", "env": "prod"}, []*ecs.Tag{tag("env", "prod"), tag("team", "payments")}, true},
+	}
+	for _, test := range tests {
+		filter, err := NewClusterFilter(nil, nil, test.required)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filter.MatchesTags(test.tags); got != test.want {
+			t.Errorf("required %v matches %v = %t, want %t", test.required, test.tags, got, test.want)
+		}
+	}
+}
+
+func TestNewClusterFilterRejectsBadPatterns(t *testing.T) {
+	for _, pattern := range []string{"regex:(", "web[", "regex:[a-"} {
+		if _, err := NewClusterFilter([]string{pattern}, nil, nil); err == nil {
+			t.Errorf("include %q compiled", pattern)
+		}
+		if _, err := NewClusterFilter(nil, []string{pattern}, nil); err == nil {
+			t.Errorf("exclude %q compiled", pattern)
+		}
+	}
+}
+
+func TestGetClustersAppliesFilter(t *testing.T) {
+	fake := NewFakeAWS()
+	for _, name := range []string{"web", "web-staging", "api", "batch"} {
+		cluster := fake.AddCluster(name)
+		fake.AddAutoScalingGroup(name+"-asg", *cluster.Cluster.ClusterArn, 1, 3, 1, 1024, 2048)
+	}
+	fake.TagCluster(fakeAccountPrefix+"cluster/web", "ecs-manager", "enabled")
+	fake.TagCluster(fakeAccountPrefix+"cluster/api", "ecs-manager", "enabled")
+	fake.TagCluster(fakeAccountPrefix+"cluster/batch", "ecs-manager", "disabled")
+
+	filter, err := NewClusterFilter(nil, []string{"*-staging"}, map[string]string{"ecs-manager": "enabled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewFakeClient(fake)
+	client.SetClusterFilter(filter)
+
+	clusters, err := client.GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, cluster := range clusters {
+		names = append(names, *cluster.ClusterName)
+	}
+	sort.Strings(names)
+	if want := []string{"api", "web"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("clusters = %q, want %q", names, want)
+	}
+
+	//only the two opted-in clusters have their instances listed
+	if calls := countCalls(fake, "ListContainerInstances"); calls != 2 {
+		t.Fatalf("ListContainerInstances called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,148 @@
+package ecs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/go-errors/errors"
+)
+
+// Maximum number of instance ids EC2 accepts in a single DescribeInstances call
+const describeInstancesLimit = 1000
+
+// ssmImagePrefix marks a launch template image id resolved from an SSM parameter
+const ssmImagePrefix = "resolve:ssm:"
+
+// DescribeImages sets the ImageId of every container instance from EC2
+func (c *ClusterDetails) DescribeImages() error {
+	instances := make(map[string]*ContainerInstance)
+	instanceIds := make([]*string, 0, len(c.ContainerInstances))
+	for _, containerInstance := range c.ContainerInstances {
+		if containerInstance.EC2InstanceId == nil {
+			continue
+		}
+		instances[*containerInstance.EC2InstanceId] = containerInstance
+		instanceIds = append(instanceIds, containerInstance.EC2InstanceId)
+	}
+
+	for _, instanceIdsChunk := range chunk(instanceIds, describeInstancesLimit) {
+		req := ec2.DescribeInstancesInput{InstanceIds: instanceIdsChunk}
+		for {
+			var res *ec2.DescribeInstancesOutput
+			err := retry("DescribeInstances", func() (err error) {
+				res, err = c.client.ec2Service.DescribeInstances(&req)
+				return err
+			})
+			if err != nil {
+				return errors.Wrap(err, 1)
+			}
+
+			for _, reservation := range res.Reservations {
+				for _, instance := range reservation.Instances {
+					if containerInstance, ok := instances[aws.StringValue(instance.InstanceId)]; ok {
+						containerInstance.ImageId = instance.ImageId
+					}
+				}
+			}
+			if res.NextToken == nil {
+				break
+			}
+			req.NextToken = res.NextToken
+		}
+	}
+	return nil
+}
+
+// LaunchImageId returns the image of the group's launch template or configuration
+func (c *ClusterDetails) LaunchImageId() (string, error) {
+	group := c.AutoScalingGroup
+	if group == nil {
+		return "", errors.New("cluster has no Auto Scaling group")
+	}
+
+	if group.LaunchTemplate != nil {
+		req := ec2.DescribeLaunchTemplateVersionsInput{
+			LaunchTemplateId:   group.LaunchTemplate.LaunchTemplateId,
+			LaunchTemplateName: group.LaunchTemplate.LaunchTemplateName,
+			Versions:           []*string{aws.String("$Default")},
+		}
+		if group.LaunchTemplate.Version != nil {
+			req.Versions = []*string{group.LaunchTemplate.Version}
+		}
+		//a template is named either by id or by name, never both
+		if req.LaunchTemplateId != nil {
+			req.LaunchTemplateName = nil
+		}
+
+		var res *ec2.DescribeLaunchTemplateVersionsOutput
+		err := retry("DescribeLaunchTemplateVersions", func() (err error) {
+			res, err = c.client.ec2Service.DescribeLaunchTemplateVersions(&req)
+			return err
+		})
+		if err != nil {
+			return "", errors.Wrap(err, 1)
+		}
+		if len(res.LaunchTemplateVersions) == 0 || res.LaunchTemplateVersions[0].LaunchTemplateData == nil || res.LaunchTemplateVersions[0].LaunchTemplateData.ImageId == nil {
+			return "", errors.New(fmt.Sprintf("launch template of %s has no image id", *group.Name))
+		}
+		imageId := *res.LaunchTemplateVersions[0].LaunchTemplateData.ImageId
+		if strings.HasPrefix(imageId, ssmImagePrefix) {
+			return c.ParameterImageId(strings.TrimPrefix(imageId, ssmImagePrefix))
+		}
+		return imageId, nil
+	}
+
+	if group.LaunchConfigurationName != nil {
+		var res *autoscaling.DescribeLaunchConfigurationsOutput
+		err := retry("DescribeLaunchConfigurations", func() (err error) {
+			res, err = c.client.autoscalingService.DescribeLaunchConfigurations(&autoscaling.DescribeLaunchConfigurationsInput{
+				LaunchConfigurationNames: []*string{group.LaunchConfigurationName},
+			})
+			return err
+		})
+		if err != nil {
+			return "", errors.Wrap(err, 1)
+		}
+		if len(res.LaunchConfigurations) == 0 || res.LaunchConfigurations[0].ImageId == nil {
+			return "", errors.New(fmt.Sprintf("launch configuration %s not found", *group.LaunchConfigurationName))
+		}
+		return *res.LaunchConfigurations[0].ImageId, nil
+	}
+	return "", errors.New(fmt.Sprintf("%s has neither a launch template nor a launch configuration", *group.Name))
+}
+
+// ParameterImageId returns the image id held by an SSM parameter
+func (c *ClusterDetails) ParameterImageId(name string) (string, error) {
+	if c.client.ssmService == nil {
+		return "", errors.New("no SSM client configured")
+	}
+
+	var res *ssm.GetParameterOutput
+	err := retry("GetParameter", func() (err error) {
+		res, err = c.client.ssmService.GetParameter(&ssm.GetParameterInput{Name: aws.String(name)})
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrap(err, 1)
+	}
+	if res.Parameter == nil || res.Parameter.Value == nil {
+		return "", errors.New(fmt.Sprintf("SSM parameter %s has no value", name))
+	}
+	return *res.Parameter.Value, nil
+}
+
+// launchTemplateOf returns the launch template an Auto Scaling group starts
+// instances from, directly or through its mixed instances policy
+func launchTemplateOf(group *autoscaling.Group) *autoscaling.LaunchTemplateSpecification {
+	if group.LaunchTemplate != nil {
+		return group.LaunchTemplate
+	}
+	if group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+		return group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package ecs
+
+import (
+	"testing"
+)
+
+func TestDescribeImages(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 1, 1024, 2048)
+	group.ImageId = "ami-00000002"
+	if err := describeFake(t, fake).IncreaseClusterCapacity(1); err != nil {
+		t.Fatal(err)
+	}
+	cluster := describeFake(t, fake)
+
+	if err := cluster.DescribeImages(); err != nil {
+		t.Fatal(err)
+	}
+	images := make(map[string]int)
+	for _, instance := range cluster.ContainerInstances {
+		if instance.ImageId == nil {
+			t.Fatalf("instance %s has no image", *instance.ContainerInstanceArn)
+		}
+		images[*instance.ImageId]++
+	}
+	if len(images) != 2 || images["ami-00000001"] != 1 || images["ami-00000002"] != 1 {
+		t.Fatalf("images = %v, want one instance on each AMI", images)
+	}
+
+	imageId, err := cluster.LaunchImageId()
+	if err != nil || imageId != "ami-00000002" {
+		t.Fatalf("launch image = %q, %v, want ami-00000002", imageId, err)
+	}
+}
+
+func TestParameterImageId(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 1, 1024, 2048)
+	fake.Parameters = map[string]string{"/ecs/image_id": "ami-00000003"}
+	cluster := describeFake(t, fake)
+
+	if imageId, err := cluster.ParameterImageId("/ecs/image_id"); err != nil || imageId != "ami-00000003" {
+		t.Fatalf("parameter image = %q, %v, want ami-00000003", imageId, err)
+	}
+	if _, err := cluster.ParameterImageId("/ecs/missing"); err == nil {
+		t.Fatal("reading a missing parameter succeeded")
+	}
+}
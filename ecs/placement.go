@@ -0,0 +1,167 @@
+package ecs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DrainSimulation is the outcome of SimulateDrain
+type DrainSimulation struct {
+	// Placements maps the arn of every moved task to the instance it would run on
+	Placements map[string]string
+	// Unplaced holds the tasks no remaining instance has room for
+	Unplaced []*Task
+	// Reason explains why the drain would strand tasks, empty when it would not
+	Reason string
+}
+
+// Fits reports whether every task on the drained instances can be re-placed
+func (s *DrainSimulation) Fits() bool {
+	return s.Reason == ""
+}
+
+// placementTarget is an instance that keeps running during a simulated drain
+type placementTarget struct {
+	instance        *ContainerInstance
+	zone            string
+	remainingCPU    int64
+	remainingMemory int64
+}
+
+// SimulateDrain places the instances' tasks on the others like spread placement
+func (c *ClusterDetails) SimulateDrain(containerInstanceArns ...*string) *DrainSimulation {
+	simulation := &DrainSimulation{
+		Placements: make(map[string]string),
+		Unplaced:   make([]*Task, 0),
+	}
+
+	drained := make(map[string]bool)
+	for _, containerInstanceArn := range containerInstanceArns {
+		drained[*containerInstanceArn] = true
+	}
+
+	zones := make(map[string]string)
+	targets := make([]*placementTarget, 0)
+	for _, instance := range c.ContainerInstances {
+		zone := ""
+		if instance.AvailabilityZone != nil {
+			zone = *instance.AvailabilityZone
+		}
+		zones[*instance.ContainerInstanceArn] = zone
+
+		if drained[*instance.ContainerInstanceArn] || instance.Status == nil || *instance.Status != "ACTIVE" || instance.AgentConnected == nil || !*instance.AgentConnected {
+			continue
+		}
+		targets = append(targets, &placementTarget{
+			instance:        instance,
+			zone:            zone,
+			remainingCPU:    int64Value(instance.RemainingCPU),
+			remainingMemory: int64Value(instance.RemainingMemory),
+		})
+	}
+
+	//tasks of each group per availability zone, before and after the drain
+	zonesBefore := make(map[string]map[string]int)
+	zonesAfter := make(map[string]map[string]int)
+	moving := make([]*Task, 0)
+	for _, task := range c.Tasks {
+		if task.ContainerInstanceArn == nil || (task.DesiredStatus != nil && *task.DesiredStatus == "STOPPED") {
+			continue
+		}
+		//daemon tasks stop with their instance, every other one already runs a copy
+		if c.IsDaemonTask(task) {
+			continue
+		}
+		group := taskGroup(task)
+		zone := zones[*task.ContainerInstanceArn]
+		countTask(zonesBefore, group, zone)
+		if drained[*task.ContainerInstanceArn] {
+			moving = append(moving, task)
+		} else {
+			countTask(zonesAfter, group, zone)
+		}
+	}
+
+	//largest tasks first, the smaller ones fill the gaps they leave
+	sort.SliceStable(moving, func(i, j int) bool {
+		if taskCPU(moving[i]) != taskCPU(moving[j]) {
+			return taskCPU(moving[i]) > taskCPU(moving[j])
+		}
+		return taskMemory(moving[i]) > taskMemory(moving[j])
+	})
+
+	for _, task := range moving {
+		group := taskGroup(task)
+		var best *placementTarget
+		for _, target := range targets {
+			if target.remainingCPU < taskCPU(task) || target.remainingMemory < taskMemory(task) {
+				continue
+			}
+			if best == nil {
+				best = target
+				continue
+			}
+			targetSpread, bestSpread := zonesAfter[group][target.zone], zonesAfter[group][best.zone]
+			if targetSpread < bestSpread || (targetSpread == bestSpread && target.remainingCPU < best.remainingCPU) {
+				best = target
+			}
+		}
+
+		if best == nil {
+			simulation.Unplaced = append(simulation.Unplaced, task)
+			continue
+		}
+		best.remainingCPU -= taskCPU(task)
+		best.remainingMemory -= taskMemory(task)
+		countTask(zonesAfter, group, best.zone)
+		simulation.Placements[*task.TaskArn] = *best.instance.ContainerInstanceArn
+	}
+
+	if len(simulation.Unplaced) > 0 {
+		simulation.Reason = fmt.Sprintf("%d of %d tasks do not fit on the remaining instances", len(simulation.Unplaced), len(moving))
+		return simulation
+	}
+	for _, task := range moving {
+		group := taskGroup(task)
+		if group != "" && len(zonesBefore[group]) > 1 && len(zonesAfter[group]) < 2 {
+			simulation.Reason = fmt.Sprintf("tasks of %s would all run in one availability zone", group)
+			return simulation
+		}
+	}
+	return simulation
+}
+
+func countTask(counts map[string]map[string]int, group string, zone string) {
+	if counts[group] == nil {
+		counts[group] = make(map[string]int)
+	}
+	counts[group][zone]++
+}
+
+func taskGroup(task *Task) string {
+	if task.Group == nil {
+		return ""
+	}
+	return *task.Group
+}
+
+func taskCPU(task *Task) int64 {
+	if task.CPU == nil {
+		return 0
+	}
+	return int64(*task.CPU)
+}
+
+func taskMemory(task *Task) int64 {
+	if task.Memory == nil {
+		return 0
+	}
+	return int64(*task.Memory)
+}
+
+func int64Value(value *int64) int64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
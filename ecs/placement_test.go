@@ -0,0 +1,147 @@
+package ecs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// placementCluster builds a cluster from instances given as
+// arn:zone:remaining cpu:remaining memory, all ACTIVE and connected
+func placementCluster(instances ...[]interface{}) *ClusterDetails {
+	cluster := &ClusterDetails{}
+	for _, fields := range instances {
+		cluster.ContainerInstances = append(cluster.ContainerInstances, &ContainerInstance{
+			ContainerInstanceArn: aws.String(fields[0].(string)),
+			AvailabilityZone:     aws.String(fields[1].(string)),
+			RemainingCPU:         aws.Int64(int64(fields[2].(int))),
+			RemainingMemory:      aws.Int64(int64(fields[3].(int))),
+			Status:               aws.String("ACTIVE"),
+			AgentConnected:       aws.Bool(true),
+		})
+	}
+	return cluster
+}
+
+func addPlacementTask(cluster *ClusterDetails, taskArn string, containerInstanceArn string, group string, cpu int, memory int) {
+	task := &Task{
+		TaskArn:              aws.String(taskArn),
+		ContainerInstanceArn: aws.String(containerInstanceArn),
+		DesiredStatus:        aws.String("RUNNING"),
+		CPU:                  &cpu,
+		Memory:               &memory,
+	}
+	if group != "" {
+		task.Group = aws.String(group)
+	}
+	cluster.Tasks = append(cluster.Tasks, task)
+}
+
+func TestSimulateDrainBinPacks(t *testing.T) {
+	cluster := placementCluster(
+		[]interface{}{"drained", "us-west-2a", 0, 0},
+		[]interface{}{"large", "us-west-2a", 1024, 2048},
+		[]interface{}{"small", "us-west-2a", 768, 1024},
+	)
+	addPlacementTask(cluster, "256", "drained", "", 256, 512)
+	addPlacementTask(cluster, "1024", "drained", "", 1024, 1024)
+	addPlacementTask(cluster, "512", "drained", "", 512, 512)
+
+	//the largest task takes the only instance it fits, the smaller ones fill
+	//whichever instance they leave the least room on
+	simulation := cluster.SimulateDrain(aws.String("drained"))
+	want := map[string]string{"1024": "large", "512": "small", "256": "small"}
+	if !simulation.Fits() || !reflect.DeepEqual(simulation.Placements, want) {
+		t.Fatalf("placements = %v (%s), want %v", simulation.Placements, simulation.Reason, want)
+	}
+}
+
+func TestSimulateDrainReportsUnplacedTasks(t *testing.T) {
+	cluster := placementCluster(
+		[]interface{}{"drained", "us-west-2a", 0, 0},
+		[]interface{}{"cpu", "us-west-2a", 2048, 256},
+		[]interface{}{"memory", "us-west-2a", 256, 4096},
+	)
+	cluster.ContainerInstances = append(cluster.ContainerInstances,
+		&ContainerInstance{ContainerInstanceArn: aws.String("draining"), AvailabilityZone: aws.String("us-west-2a"), RemainingCPU: aws.Int64(4096), RemainingMemory: aws.Int64(8192), Status: aws.String("DRAINING"), AgentConnected: aws.Bool(true)},
+		&ContainerInstance{ContainerInstanceArn: aws.String("disconnected"), AvailabilityZone: aws.String("us-west-2a"), RemainingCPU: aws.Int64(4096), RemainingMemory: aws.Int64(8192), Status: aws.String("ACTIVE"), AgentConnected: aws.Bool(false)},
+	)
+	addPlacementTask(cluster, "small", "drained", "", 128, 128)
+	addPlacementTask(cluster, "large", "drained", "", 1024, 1024)
+	//a stopping task does not need a new home
+	addPlacementTask(cluster, "stopping", "drained", "", 4096, 8192)
+	cluster.Tasks[2].DesiredStatus = aws.String("STOPPED")
+
+	simulation := cluster.SimulateDrain(aws.String("drained"))
+	if simulation.Fits() {
+		t.Fatalf("drain fits with placements %v, want the large task stranded", simulation.Placements)
+	}
+	if len(simulation.Unplaced) != 1 || *simulation.Unplaced[0].TaskArn != "large" {
+		t.Fatalf("unplaced = %v, want only the large task", simulation.Unplaced)
+	}
+	if want := "1 of 2 tasks do not fit on the remaining instances"; simulation.Reason != want {
+		t.Fatalf("reason = %q, want %q", simulation.Reason, want)
+	}
+}
+
+func TestSimulateDrainSpreadsAcrossZones(t *testing.T) {
+	cluster := placementCluster(
+		[]interface{}{"a1", "us-west-2a", 512, 1024},
+		[]interface{}{"a2", "us-west-2a", 256, 512},
+		[]interface{}{"b1", "us-west-2b", 512, 1024},
+		[]interface{}{"b2", "us-west-2b", 2048, 4096},
+	)
+	addPlacementTask(cluster, "api-a", "a1", "service:api", 256, 512)
+	addPlacementTask(cluster, "api-a2", "a1", "service:api", 256, 512)
+	addPlacementTask(cluster, "api-b", "b1", "service:api", 256, 512)
+
+	//a2 is the tightest fit but the api group already runs twice in us-west-2a
+	simulation := cluster.SimulateDrain(aws.String("b1"))
+	if !simulation.Fits() || simulation.Placements["api-b"] != "b2" {
+		t.Fatalf("placements = %v (%s), want api-b kept in us-west-2b on b2", simulation.Placements, simulation.Reason)
+	}
+
+	//with us-west-2b gone the group would run in one zone only
+	simulation = cluster.SimulateDrain(aws.String("b1"), aws.String("b2"))
+	if want := "tasks of service:api would all run in one availability zone"; simulation.Reason != want {
+		t.Fatalf("reason = %q, want %q", simulation.Reason, want)
+	}
+	if len(simulation.Unplaced) != 0 {
+		t.Fatalf("unplaced = %v, want every task placed", simulation.Unplaced)
+	}
+}
+
+func TestSimulateDrainAllowsSingleZoneGroups(t *testing.T) {
+	cluster := placementCluster(
+		[]interface{}{"a1", "us-west-2a", 512, 1024},
+		[]interface{}{"a2", "us-west-2a", 512, 1024},
+	)
+	addPlacementTask(cluster, "worker", "a1", "service:worker", 256, 512)
+
+	simulation := cluster.SimulateDrain(aws.String("a1"))
+	if !simulation.Fits() || simulation.Placements["worker"] != "a2" {
+		t.Fatalf("placements = %v (%s), want the worker moved to a2", simulation.Placements, simulation.Reason)
+	}
+}
+
+func TestSimulateDrainLeavesDaemonTasks(t *testing.T) {
+	fake := NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 3, 2, 1024, 2048)
+	fake.AddDaemonService(clusterArn, "agent", 768, 1536)
+	cluster := describeFake(t, fake)
+	drained := cluster.ContainerInstances[0].ContainerInstanceArn
+	task := fake.AddTask(clusterArn, *drained, 256, 512)
+	cluster = describeFake(t, fake)
+
+	//the other instance only has room for the standalone task, its own agent
+	//task already runs there
+	simulation := cluster.SimulateDrain(drained)
+	if !simulation.Fits() {
+		t.Fatalf("drain does not fit: %s", simulation.Reason)
+	}
+	if len(simulation.Placements) != 1 || simulation.Placements[*task.TaskArn] == "" {
+		t.Fatalf("placements = %v, want only the standalone task moved", simulation.Placements)
+	}
+}
@@ -0,0 +1,98 @@
+package leader
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/go-errors/errors"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB API used by DynamoDBLeaseStore
+type DynamoDBAPI interface {
+	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+}
+
+// DynamoDBLeaseStore keeps leases in a table keyed by LeaseName
+type DynamoDBLeaseStore struct {
+	service   DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBLeaseStore returns a DynamoDBLeaseStore using the given table
+func NewDynamoDBLeaseStore(service DynamoDBAPI, tableName string) *DynamoDBLeaseStore {
+	return &DynamoDBLeaseStore{
+		service:   service,
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBLeaseStore) Acquire(name string, holder string, duration time.Duration) (*Lease, error) {
+	now := time.Now()
+	lease := &Lease{Name: name, Holder: holder, ExpiresAt: now.Add(duration)}
+
+	_, err := s.service.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"LeaseName": {S: aws.String(name)},
+			"Holder":    {S: aws.String(holder)},
+			"ExpiresAt": {N: aws.String(strconv.FormatInt(lease.ExpiresAt.UnixNano(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(LeaseName) OR Holder = :holder OR ExpiresAt <= :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":holder": {S: aws.String(holder)},
+			":now":    {N: aws.String(strconv.FormatInt(now.UnixNano(), 10))},
+		},
+	})
+	if err == nil {
+		return lease, nil
+	}
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil, errors.Wrap(err, 1)
+	}
+
+	// someone else holds the lease, report who
+	res, err := s.service.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            map[string]*dynamodb.AttributeValue{"LeaseName": {S: aws.String(name)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+
+	current := &Lease{Name: name}
+	if value, ok := res.Item["Holder"]; ok && value.S != nil {
+		current.Holder = *value.S
+	}
+	if value, ok := res.Item["ExpiresAt"]; ok && value.N != nil {
+		expiresAt, err := strconv.ParseInt(*value.N, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, 1)
+		}
+		current.ExpiresAt = time.Unix(0, expiresAt)
+	}
+	return current, nil
+}
+
+func (s *DynamoDBLeaseStore) Release(name string, holder string) error {
+	_, err := s.service.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 map[string]*dynamodb.AttributeValue{"LeaseName": {S: aws.String(name)}},
+		ConditionExpression: aws.String("Holder = :holder"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":holder": {S: aws.String(holder)},
+		},
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
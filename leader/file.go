@@ -0,0 +1,112 @@
+package leader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// FileLeaseStore keeps leases in a local JSON file, using an advisory lock so
+// replicas on the same host never both win
+type FileLeaseStore struct {
+	fileName string
+	// Now is used to judge expiry, defaults to time.Now
+	Now func() time.Time
+}
+
+// NewFileLeaseStore returns a FileLeaseStore backed by the given file
+func NewFileLeaseStore(fileName string) *FileLeaseStore {
+	return &FileLeaseStore{fileName: fileName}
+}
+
+func (s *FileLeaseStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// update runs change against the leases in the file while holding an
+// exclusive lock on it, writing them back when change returns true
+func (s *FileLeaseStore) update(change func(leases map[string]Lease) bool) error {
+	file, err := os.OpenFile(s.fileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	defer file.Close()
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	leases := make(map[string]Lease)
+	if len(content) > 0 {
+		err = json.Unmarshal(content, &leases)
+		if err != nil {
+			return errors.Wrap(err, 1)
+		}
+	}
+
+	if !change(leases) {
+		return nil
+	}
+
+	content, err = json.Marshal(leases)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	err = file.Truncate(0)
+	if err == nil {
+		_, err = file.WriteAt(content, 0)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
+
+func (s *FileLeaseStore) Acquire(name string, holder string, duration time.Duration) (*Lease, error) {
+	var lease Lease
+	err := s.update(func(leases map[string]Lease) bool {
+		now := s.now()
+		current, ok := leases[name]
+		if ok && current.Holder != holder && !current.Expired(now) {
+			lease = current
+			return false
+		}
+		lease = Lease{Name: name, Holder: holder, ExpiresAt: now.Add(duration)}
+		leases[name] = lease
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+	return &lease, nil
+}
+
+func (s *FileLeaseStore) Release(name string, holder string) error {
+	err := s.update(func(leases map[string]Lease) bool {
+		if current, ok := leases[name]; ok && current.Holder == holder {
+			delete(leases, name)
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
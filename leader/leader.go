@@ -0,0 +1,150 @@
+package leader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Lease is held by the active manager replica until ExpiresAt
+type Lease struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the lease can be taken over at the given time
+func (l *Lease) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+// LeaseStore is where replicas compete for a lease
+type LeaseStore interface {
+	// Acquire takes or extends the lease for holder and returns it as stored
+	Acquire(name string, holder string, duration time.Duration) (*Lease, error)
+	// Release gives up the named lease if holder still holds it
+	Release(name string, holder string) error
+}
+
+// Elector campaigns for leadership on behalf of one replica
+type Elector struct {
+	store    LeaseStore
+	name     string
+	identity string
+	duration time.Duration
+	// Now is used to judge the lease's expiry, defaults to time.Now
+	Now func() time.Time
+
+	mutex    sync.RWMutex
+	lease    *Lease
+	isLeader bool
+}
+
+// NewElector returns an Elector competing for the named lease as identity.
+// Campaign must be called more often than duration to keep leadership.
+func NewElector(store LeaseStore, name string, identity string, duration time.Duration) *Elector {
+	return &Elector{
+		store:    store,
+		name:     name,
+		identity: identity,
+		duration: duration,
+	}
+}
+
+func (e *Elector) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// Campaign acquires or renews the lease, an error leaves this replica a follower
+func (e *Elector) Campaign() (bool, error) {
+	lease, err := e.store.Acquire(e.name, e.identity, e.duration)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	wasLeader := e.isLeader
+	if err != nil {
+		e.isLeader = false
+		if wasLeader {
+			logrus.WithFields(logrus.Fields{
+				"Identity": e.identity,
+			}).Warn("Lost leadership, could not renew lease")
+		}
+		return false, errors.Wrap(err, 1)
+	}
+
+	e.lease = lease
+	e.isLeader = lease.Holder == e.identity && !lease.Expired(e.now())
+	if e.isLeader && !wasLeader {
+		logrus.WithFields(logrus.Fields{
+			"Identity":  e.identity,
+			"ExpiresAt": lease.ExpiresAt,
+		}).Info("Acquired leadership")
+	} else if !e.isLeader && wasLeader {
+		logrus.WithFields(logrus.Fields{
+			"Identity": e.identity,
+			"Leader":   lease.Holder,
+		}).Warn("Lost leadership")
+	}
+	return e.isLeader, nil
+}
+
+// Renew reports whether this replica still leads, renewing the lease when it runs low
+func (e *Elector) Renew() (bool, error) {
+	e.mutex.RLock()
+	isLeader, lease := e.isLeader, e.lease
+	e.mutex.RUnlock()
+
+	if !isLeader {
+		return false, nil
+	}
+	if lease != nil && lease.ExpiresAt.Sub(e.now()) > e.duration/2 {
+		return true, nil
+	}
+	return e.Campaign()
+}
+
+// Resign releases the lease so another replica can take over immediately
+func (e *Elector) Resign() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.isLeader {
+		return nil
+	}
+	e.isLeader = false
+	e.lease = nil
+	err := e.store.Release(e.name, e.identity)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
+
+// IsLeader reports whether the last campaign won the lease
+func (e *Elector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+// Identity returns the name this replica campaigns under
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// Leader returns the identity of the replica holding the lease as of the
+// last campaign, or an empty string when unknown
+func (e *Elector) Leader() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.lease == nil || e.lease.Expired(e.now()) {
+		return ""
+	}
+	return e.lease.Holder
+}
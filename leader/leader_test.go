@@ -0,0 +1,167 @@
+package leader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// clock is a settable time shared by a store and its electors
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// leaseStores builds each backend that runs without AWS on the given clock
+var leaseStores = map[string]func(t *testing.T, clock *clock) LeaseStore{
+	"memory": func(t *testing.T, clock *clock) LeaseStore {
+		store := NewMemoryLeaseStore()
+		store.Now = clock.Now
+		return store
+	},
+	"file": func(t *testing.T, clock *clock) LeaseStore {
+		store := NewFileLeaseStore(filepath.Join(t.TempDir(), "leader.json"))
+		store.Now = clock.Now
+		return store
+	},
+}
+
+func acquire(t *testing.T, store LeaseStore, holder string, duration time.Duration) *Lease {
+	t.Helper()
+	lease, err := store.Acquire("ecs-manager", holder, duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lease
+}
+
+func TestLeaseStores(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, newStore := range leaseStores {
+		t.Run(name, func(t *testing.T) {
+			clock := &clock{now: start}
+			store := newStore(t, clock)
+
+			//acquire a free lease
+			lease := acquire(t, store, "a", 15*time.Second)
+			if lease.Holder != "a" || !lease.ExpiresAt.Equal(start.Add(15*time.Second)) {
+				t.Fatalf("first acquire = %+v, want a until %s", lease, start.Add(15*time.Second))
+			}
+
+			//refused while another replica holds it
+			clock.now = start.Add(10 * time.Second)
+			lease = acquire(t, store, "b", 15*time.Second)
+			if lease.Holder != "a" {
+				t.Fatalf("acquire while held = %+v, want a to keep it", lease)
+			}
+
+			//renewed by its holder
+			lease = acquire(t, store, "a", 15*time.Second)
+			if lease.Holder != "a" || !lease.ExpiresAt.Equal(start.Add(25*time.Second)) {
+				t.Fatalf("renew = %+v, want a until %s", lease, start.Add(25*time.Second))
+			}
+
+			//still refused until the renewed lease expires
+			clock.now = start.Add(20 * time.Second)
+			if lease = acquire(t, store, "b", 15*time.Second); lease.Holder != "a" {
+				t.Fatalf("acquire before renewed expiry = %+v, want a to keep it", lease)
+			}
+
+			//stolen once expired
+			clock.now = start.Add(25 * time.Second)
+			lease = acquire(t, store, "b", 15*time.Second)
+			if lease.Holder != "b" || !lease.ExpiresAt.Equal(start.Add(40*time.Second)) {
+				t.Fatalf("acquire after expiry = %+v, want b until %s", lease, start.Add(40*time.Second))
+			}
+			if lease = acquire(t, store, "a", 15*time.Second); lease.Holder != "b" {
+				t.Fatalf("previous holder acquire = %+v, want b to keep it", lease)
+			}
+
+			//released only by its holder
+			if err := store.Release("ecs-manager", "a"); err != nil {
+				t.Fatal(err)
+			}
+			if lease = acquire(t, store, "c", 15*time.Second); lease.Holder != "b" {
+				t.Fatalf("acquire after release by another = %+v, want b to keep it", lease)
+			}
+			if err := store.Release("ecs-manager", "b"); err != nil {
+				t.Fatal(err)
+			}
+			if lease = acquire(t, store, "c", 15*time.Second); lease.Holder != "c" {
+				t.Fatalf("acquire after release = %+v, want c", lease)
+			}
+		})
+	}
+}
+
+func TestElectorFailover(t *testing.T) {
+	for name, newStore := range leaseStores {
+		t.Run(name, func(t *testing.T) {
+			clock := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			store := newStore(t, clock)
+			first := NewElector(store, "ecs-manager", "a", 15*time.Second)
+			first.Now = clock.Now
+			second := NewElector(store, "ecs-manager", "b", 15*time.Second)
+			second.Now = clock.Now
+
+			if leading, err := first.Campaign(); err != nil || !leading {
+				t.Fatalf("first campaign = %t, %v, want leading", leading, err)
+			}
+			if leading, err := second.Campaign(); err != nil || leading {
+				t.Fatalf("second campaign = %t, %v, want standby", leading, err)
+			}
+			if leader := second.Leader(); leader != "a" {
+				t.Fatalf("second sees leader %q, want a", leader)
+			}
+
+			//the first replica stalls past its lease and the second takes over
+			clock.now = clock.now.Add(16 * time.Second)
+			if leading, err := second.Campaign(); err != nil || !leading {
+				t.Fatalf("second campaign after expiry = %t, %v, want leading", leading, err)
+			}
+			if leading, err := first.Renew(); err != nil || leading {
+				t.Fatalf("first renew after takeover = %t, %v, want lost", leading, err)
+			}
+			if first.IsLeader() {
+				t.Fatal("first still leader after losing the lease")
+			}
+		})
+	}
+}
+
+func TestElectorRenew(t *testing.T) {
+	clock := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryLeaseStore()
+	store.Now = clock.Now
+	elector := NewElector(store, "ecs-manager", "a", 10*time.Second)
+	elector.Now = clock.Now
+
+	if leading, _ := elector.Renew(); leading {
+		t.Fatal("renew before any campaign reported leading")
+	}
+	if leading, err := elector.Campaign(); err != nil || !leading {
+		t.Fatalf("campaign = %t, %v, want leading", leading, err)
+	}
+	expiresAt := clock.now.Add(10 * time.Second)
+
+	//more than half the lease left, nothing to renew
+	clock.now = clock.now.Add(4 * time.Second)
+	if leading, err := elector.Renew(); err != nil || !leading {
+		t.Fatalf("renew = %t, %v, want leading", leading, err)
+	}
+	if lease := acquire(t, store, "b", 10*time.Second); !lease.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("lease expires at %s, want %s unchanged", lease.ExpiresAt, expiresAt)
+	}
+
+	//running low, the lease is extended
+	clock.now = clock.now.Add(2 * time.Second)
+	if leading, err := elector.Renew(); err != nil || !leading {
+		t.Fatalf("renew = %t, %v, want leading", leading, err)
+	}
+	if lease := acquire(t, store, "b", 10*time.Second); !lease.ExpiresAt.Equal(clock.now.Add(10 * time.Second)) {
+		t.Fatalf("lease expires at %s, want %s", lease.ExpiresAt, clock.now.Add(10*time.Second))
+	}
+}
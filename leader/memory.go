@@ -0,0 +1,50 @@
+package leader
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryLeaseStore keeps leases in memory, letting electors in one process
+// compete for them in tests
+type MemoryLeaseStore struct {
+	mutex  sync.Mutex
+	leases map[string]Lease
+	// Now is used to judge expiry, defaults to time.Now
+	Now func() time.Time
+}
+
+// NewMemoryLeaseStore returns an empty MemoryLeaseStore
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{leases: make(map[string]Lease)}
+}
+
+func (s *MemoryLeaseStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *MemoryLeaseStore) Acquire(name string, holder string, duration time.Duration) (*Lease, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	lease, ok := s.leases[name]
+	if !ok || lease.Holder == holder || lease.Expired(now) {
+		lease = Lease{Name: name, Holder: holder, ExpiresAt: now.Add(duration)}
+		s.leases[name] = lease
+	}
+	return &lease, nil
+}
+
+func (s *MemoryLeaseStore) Release(name string, holder string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if lease, ok := s.leases[name]; ok && lease.Holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
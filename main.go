@@ -2,26 +2,71 @@ package main
 
 import (
 	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/check"
 	"github.com/sd-charris/ecs-manager/config"
 	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/leader"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sd-charris/ecs-manager/notify"
+	"github.com/sd-charris/ecs-manager/state"
 	"github.com/sd-charris/logrus-cloudwatchlogs"
 	"github.com/go-errors/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sns"
 	"time"
 	"log"
 	"strconv"
+	"strings"
+	"flag"
+	"fmt"
+	"os"
+	"net/http"
+	"reflect"
 )
 
 var ecsClusters map[string]*ECSCluster
 
+// dryRun makes reconcileAlerts plan and record actions without sending them to AWS
+var dryRun bool
+
+// stateStore persists cluster alerts between runs, nil when disabled
+var stateStore state.Store
+
+// elector decides which replica may act on alerts, nil when only one replica runs
+var elector *leader.Elector
+
+// httpServer serves the metrics and status API, nil when HTTPListenAddress is unset
+var httpServer *http.Server
+
+// snsClients publishes SNS notifications, keyed by the topic's region
+var snsClients = make(map[string]*sns.SNS)
+
+//built-in alert checks, run in this order against every cluster with instances
+func init() {
+	check.Register(check.NewFunc("ClusterResources", checkClusterResources))
+	check.Register(check.NewFunc("PendingTasks", checkPendingTasks))
+	check.Register(check.NewFunc("InstanceState", checkAllInstancesState))
+	check.Register(imageRefreshCheck{})
+}
 
 func main() {
+	dryRunFlag := flag.Bool("dry-run", false, "plan scaling actions without sending them to AWS")
+	flag.Parse()
+
 	defer func(){
-		err := recover().(error)
-		logrus.Error(err)
+		if recovered := recover(); recovered != nil {
+			logrus.Error(errors.Wrap(recovered, 2).ErrorStack())
+			logrus.Exit(exitPanic)
+		}
 	}()
-	cfg := aws.NewConfig().WithRegion("us-west-2")
+	settings, err := config.LoadConfig("./config.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := aws.NewConfig().WithRegion(settings.Region)
 
 	logStreamName := strconv.Itoa(int(time.Now().Unix()))
 	logGroupName := "/aws/ecs/manager"
@@ -32,32 +77,73 @@ func main() {
 
 	logrus.SetFormatter(&logrus.TextFormatter{})
 	logrus.AddHook(hook)
+	logrus.RegisterExitHandler(func() {
+		flushLogs(hook)
+	})
+	stop := handleSignals()
 
 	ecsClusters = make(map[string]*ECSCluster)
-	logrus.Info("Pull Configuration")
-	config.LoadConfig("./config.json")
+	logrus.WithFields(logrus.Fields{
+		"Config":  settings,
+	}).Info("Pull Configuration")
+	dryRun = settings.DryRun || *dryRunFlag
+	if dryRun {
+		logrus.Warn("Dry run enabled, scaling actions will be planned but not performed")
+	}
+	for name := range settings.Checks {
+		if check.Lookup(name) == nil {
+			logrus.WithFields(logrus.Fields{
+				"Check":  name,
+			}).Warn("Unknown check in configuration")
+		}
+	}
 
 	logrus.Info("Starting ECS Manager v1.4")
 	logrus.Info("Configure AWS ECS")
-	ecs.Initialize()
-
-	intervalSeconds := time.Duration(*config.GetConfigValueAsInt64("IntervalSeconds"))
+	accounts := make([]ecs.Account, 0, len(settings.Accounts))
+	for _, account := range settings.Accounts {
+		accounts = append(accounts, ecs.Account{RoleArn: account.RoleArn, ExternalId: account.ExternalId})
+	}
+	ecs.Initialize(settings.Regions, accounts...)
+	ecs.SetRetryPolicy(ecs.RetryPolicy{
+		MaxAttempts:  int(settings.RetryMaxAttempts),
+		BaseDelay:  time.Duration(settings.RetryBaseMilliseconds) * time.Millisecond,
+		MaxDelay:  time.Duration(settings.RetryMaxMilliseconds) * time.Millisecond,
+	})
+	clusterFilter, err := ecs.NewClusterFilter(settings.IncludeClusters, settings.ExcludeClusters, settings.ClusterOptInTags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ecs.SetClusterFilter(clusterFilter)
 
-	err = start(intervalSeconds * time.Second)
+	logrus.Info("Restore Alert State")
+	stateStore = newStateStore(cfg)
+	err = loadState()
 	if err != nil {
 		logrus.Error(err.(*errors.Error).ErrorStack())
-		panic(err)
+		logrus.Exit(exitFailure)
 	}
+
+	intervalSeconds := time.Duration(settings.IntervalSeconds)
+	elector = newElector(cfg, intervalSeconds*time.Second)
+
+	startHTTPServer()
+
+	start(intervalSeconds * time.Second, stop)
+	shutdown(exitShutdown)
 }
 
-func start(delay time.Duration) error{
+// start runs a check every delay until stop is closed, letting the pass in
+// progress finish first
+func start(delay time.Duration, stop <-chan struct{}) {
 	for {
 		select {
+		case <-stop:
+			return
 		case <-time.After(delay):
 			err := process()
 			if err != nil {
 				logrus.Error(err)
-				return errors.Wrap(err, 1)
 			}
 		}
 	}
@@ -65,10 +151,22 @@ func start(delay time.Duration) error{
 
 func process() error{
 	logrus.Info("------------------------------------------- Start Check -------------------------------------------")
+	leading := campaign()
 	clusters, err := ecs.GetClusters()
 
-
-	if err != nil {
+	//clusters that could not be described are skipped this pass, the others are still checked
+	if clusterErrs, ok := err.(ecs.ClusterErrors); ok {
+		for _, clusterErr := range clusterErrs {
+			logrus.WithFields(logrus.Fields{
+				"ClusterArn":  clusterErr.ClusterArn,
+				"Region":  clusterErr.Region,
+				"Class":  ecs.Classify(clusterErr.Err),
+			}).Error(clusterErr.Err)
+			if ecsClusters[clusterErr.ClusterArn] != nil {
+				ecsClusters[clusterErr.ClusterArn].recordPass(clusterErr.ClusterArn, clusterErr.Err)
+			}
+		}
+	} else if err != nil {
 		logrus.Error(err)
 		return errors.Wrap(err, 1)
 	}
@@ -77,25 +175,283 @@ func process() error{
 		if ecsClusters[*cluster.ClusterArn] == nil {
 			ecsClusters[*cluster.ClusterArn] = &ECSCluster{}
 		}
-		ecsClusters[*cluster.ClusterArn].ClusterDetails = cluster
+		//a lease lost during a long pass leaves the remaining clusters to the new leader
+		if leading && !holdsLeadership() {
+			logrus.Warn("Lost leadership during the check, observing the remaining clusters")
+			leading = false
+		}
+		err := processCluster(cluster, leading)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"ClusterArn":  *cluster.ClusterArn,
+			}).Error(err)
+		}
+		ecsClusters[*cluster.ClusterArn].recordPass(*cluster.ClusterArn, err)
+	}
+
+	publishStatus()
+	return nil
+}
+
+// processCluster returns a panic as an error so other clusters still run
+func processCluster(cluster *ecs.ClusterDetails, leading bool) (err error) {
+	defer func(){
+		if recovered := recover(); recovered != nil {
+			err = errors.Wrap(recovered, 2)
+			logrus.Error(err.(*errors.Error).ErrorStack())
+		}
+	}()
+
+	ecsClusters[*cluster.ClusterArn].ClusterDetails = cluster
+	ecsClusters[*cluster.ClusterArn].actionErr = nil
+	logrus.WithFields(logrus.Fields{
+		"ClusterArn":  *cluster.ClusterArn,
+		"AccountId":  cluster.AccountId,
+		"Region":  cluster.Region,
+	}).Info("---------------------------- Checking Cluster")
+
+	settings := config.Get().ForCluster(*cluster.ClusterName, *cluster.ClusterArn)
+	if !reflect.DeepEqual(settings, ecsClusters[*cluster.ClusterArn].Config) {
 		logrus.WithFields(logrus.Fields{
 			"ClusterArn":  *cluster.ClusterArn,
-		}).Info("---------------------------- Checking Cluster")
-		if len(cluster.ContainerInstances) > 0 {
-			ecsClusters[*cluster.ClusterArn].Alerts = append(ecsClusters[*cluster.ClusterArn].Alerts, checkClusterResources(cluster)...)
-			ecsClusters[*cluster.ClusterArn].Alerts = append(ecsClusters[*cluster.ClusterArn].Alerts, checkServicesDesiredCount(cluster)...)
-			ecsClusters[*cluster.ClusterArn].Alerts = append(ecsClusters[*cluster.ClusterArn].Alerts, checkAllInstancesState(cluster)...)
-			ecsClusters[*cluster.ClusterArn].Alerts = alert.ConsolidateAlerts(ecsClusters[*cluster.ClusterArn].Alerts)
-
-			for _, alert := range ecsClusters[*cluster.ClusterArn].Alerts {
-				logrus.WithFields(logrus.Fields{
-					"Alert":  alert,
-				}).Info("Reconciled Alert")
+			"AccountId":  cluster.AccountId,
+			"Overrides":  config.Get().Overrides(*cluster.ClusterName, *cluster.ClusterArn),
+			"Config":  settings,
+		}).Info("Effective Cluster Configuration")
+		ecsClusters[*cluster.ClusterArn].Sinks = newSinks(settings)
+	}
+	ecsClusters[*cluster.ClusterArn].Config = settings
+	metrics.SetClusterInstances(*cluster.ClusterArn, len(cluster.ContainerInstances))
+	if cluster.AutoScalingGroup != nil {
+		metrics.SetAutoScalingGroup(*cluster.ClusterArn, *cluster.AutoScalingGroup.Name, *cluster.AutoScalingGroup.MinInstanceCount, *cluster.AutoScalingGroup.MaxInstanceCount, *cluster.AutoScalingGroup.DesiredInstanceCount)
+	}
+	//only the leader runs schedules, standby replicas would pile up alerts
+	var newAlerts []*alert.Alert
+	if leading {
+		checkTime := time.Now()
+		since := checkTime.Add(-time.Duration(settings.ScheduleCatchUpSeconds) * time.Second)
+		if ecsClusters[*cluster.ClusterArn].LastScheduleCheck.After(since) {
+			since = ecsClusters[*cluster.ClusterArn].LastScheduleCheck
+		}
+		newAlerts = checkSchedules(cluster, settings, since, checkTime)
+		ecsClusters[*cluster.ClusterArn].LastScheduleCheck = checkTime
+	}
+
+	if len(cluster.ContainerInstances) > 0 {
+		ecsClusters[*cluster.ClusterArn].CheckResults = check.Run(cluster, settings)
+		for _, result := range ecsClusters[*cluster.ClusterArn].CheckResults {
+			newAlerts = append(newAlerts, result.Alerts...)
+		}
+	} else {
+		ecsClusters[*cluster.ClusterArn].CheckResults = nil
+	}
+	if len(cluster.ContainerInstances) > 0 || len(newAlerts) > 0 || len(ecsClusters[*cluster.ClusterArn].Alerts) > 0 {
+		for _, newAlert := range newAlerts {
+			metrics.AlertCreated(*cluster.ClusterArn, newAlert.Type.String(), newAlert.Trigger.String())
+		}
+		previous := make(map[*alert.Alert]alert.Status)
+		for _, existing := range ecsClusters[*cluster.ClusterArn].Alerts {
+			previous[existing] = existing.Status
+		}
+		ecsClusters[*cluster.ClusterArn].Alerts = append(ecsClusters[*cluster.ClusterArn].Alerts, newAlerts...)
+		ecsClusters[*cluster.ClusterArn].Alerts = alert.ConsolidateAlerts(ecsClusters[*cluster.ClusterArn].Alerts)
+
+		for _, alert := range ecsClusters[*cluster.ClusterArn].Alerts {
+			logrus.WithFields(logrus.Fields{
+				"Alert":  alert,
+			}).Info("Reconciled Alert")
+		}
+
+		if leading {
+			ecsClusters[*cluster.ClusterArn].reconcileAlerts(previous)
+			//once the lease is lost the new leader owns the saved state
+			if elector == nil || elector.IsLeader() {
+				saveState(*cluster.ClusterArn)
 			}
+		}
+	}
+	return ecsClusters[*cluster.ClusterArn].actionErr
+}
+
+// startHTTPServer serves /metrics and the status API on the HTTPListenAddress config key, if set
+func startHTTPServer() {
+	address := config.Get().HTTPListenAddress
+	if address == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	registerStatusHandlers(mux)
 
-			ecsClusters[*cluster.ClusterArn].reconcileAlerts()
+	logrus.WithFields(logrus.Fields{
+		"Address":  address,
+	}).Info("Starting HTTP Server")
+	httpServer = &http.Server{Addr: address, Handler: mux}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Error(err)
 		}
+	}()
+}
+
+// newAWSSession returns a session whose requests are reported in the AWS metrics
+func newAWSSession(cfg *aws.Config) *session.Session {
+	sess := session.Must(session.NewSession(cfg))
+	metrics.InstrumentHandlers(&sess.Handlers)
+	return sess
+}
+
+// newStateStore builds the StateStore, nil keeps alert state in memory only
+func newStateStore(cfg *aws.Config) state.Store {
+	switch config.Get().StateStore {
+	case "file":
+		return state.NewFileStore(config.Get().StateFile)
+	case "dynamodb":
+		return state.NewDynamoDBStore(dynamodb.New(newAWSSession(cfg)), config.Get().StateTable)
+	}
+	return nil
+}
+
+// newSinks builds the Notifiers sinks, leaving out any that fails
+func newSinks(settings *config.Config) []*notify.Sink {
+	sinks := make([]*notify.Sink, 0, len(settings.Notifiers))
+	for _, notifier := range settings.Notifiers {
+		var backend notify.Notifier
+		switch notifier.Type {
+		case "slack":
+			backend = notify.NewSlackNotifier(notifier.URL)
+		case "webhook":
+			backend = notify.NewWebhookNotifier(notifier.URL, notifier.Headers)
+		case "sns":
+			region := strings.Split(notifier.TopicArn, ":")[3]
+			if snsClients[region] == nil {
+				snsClients[region] = sns.New(newAWSSession(aws.NewConfig().WithRegion(region)))
+			}
+			backend = notify.NewSNSNotifier(snsClients[region], notifier.TopicArn)
+		}
+		sink, err := notify.NewSink(backend, notifier.Events, notifier.Template, int(notifier.MaxAttempts), time.Duration(notifier.BackoffMilliseconds)*time.Millisecond)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Notifier":  notifier.Type,
+			}).Error(err)
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// loadState restores the alerts saved by a previous run so in-flight
+// operations resume where they left off
+func loadState() error {
+	if stateStore == nil {
+		return nil
 	}
 
+	clusters, err := stateStore.Load()
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+
+	for clusterArn, alerts := range clusters {
+		if ecsClusters[clusterArn] == nil {
+			ecsClusters[clusterArn] = &ECSCluster{}
+		}
+		ecsClusters[clusterArn].Alerts = alerts
+		for _, alert := range alerts {
+			logrus.WithFields(logrus.Fields{
+				"Alert":  alert,
+			}).Info("Restored Alert")
+		}
+	}
 	return nil
 }
+
+// saveState persists the alerts of a cluster after they are reconciled
+func saveState(clusterArn string) {
+	if stateStore == nil {
+		return
+	}
+
+	err := stateStore.Save(clusterArn, ecsClusters[clusterArn].Alerts)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ClusterArn":  clusterArn,
+		}).Error(err)
+	}
+}
+
+// newElector builds the LeaderElection elector, nil when it is off
+func newElector(cfg *aws.Config, interval time.Duration) *leader.Elector {
+	var store leader.LeaseStore
+
+	switch config.Get().LeaderElection {
+	case "memory":
+		store = leader.NewMemoryLeaseStore()
+	case "file":
+		store = leader.NewFileLeaseStore(config.Get().LeaderLeaseFile)
+	case "dynamodb":
+		store = leader.NewDynamoDBLeaseStore(dynamodb.New(newAWSSession(cfg)), config.Get().LeaderTable)
+	default:
+		return nil
+	}
+
+	//the lease must outlive several check intervals so a slow pass does not lose it
+	leaseDuration := 3 * interval
+	if config.Get().LeaderLeaseSeconds > 0 {
+		leaseDuration = time.Duration(config.Get().LeaderLeaseSeconds) * time.Second
+	}
+
+	identity := config.Get().LeaderIdentity
+	if identity == "" {
+		hostname, _ := os.Hostname()
+		identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return leader.NewElector(store, "ecs-manager", identity, leaseDuration)
+}
+
+// holdsLeadership is checked before every change to a cluster
+func holdsLeadership() bool {
+	if elector == nil {
+		return true
+	}
+
+	leading, err := elector.Renew()
+	if err != nil {
+		logrus.Error(err)
+	}
+	return leading
+}
+
+// campaign reports whether this replica may act, taking over saved alerts when it becomes leader
+func campaign() bool {
+	if elector == nil {
+		return true
+	}
+
+	wasLeader := elector.IsLeader()
+	leading, err := elector.Campaign()
+	if err != nil {
+		logrus.Error(err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Identity":  elector.Identity(),
+		"Leader":    elector.Leader(),
+		"IsLeader":  leading,
+	}).Info("Leader Election")
+
+	if leading && !wasLeader {
+		for _, ecsCluster := range ecsClusters {
+			ecsCluster.Alerts = nil
+		}
+		err = loadState()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}
+	return leading
+}
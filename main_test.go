@@ -0,0 +1,261 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/circuit"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/leader"
+	"github.com/sd-charris/ecs-manager/notify"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestManager points the manager at fake, with the given config.json
+// document over the defaults, and clears the state left by other tests
+func newTestManager(t *testing.T, fake *ecs.FakeAWS, document string) {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(fileName, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadConfig(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	ecs.SetClients(ecs.NewFakeClient(fake))
+	ecsClusters = make(map[string]*ECSCluster)
+	dryRun = false
+	stateStore = nil
+	elector = nil
+}
+
+// runPasses runs count check passes, failing the test on an error
+func runPasses(t *testing.T, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		if err := process(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessScalesUpBusyCluster(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	cluster := fake.AddCluster("web")
+	clusterArn := *cluster.Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 5, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 4, 460, 900)
+	newTestManager(t, fake, `{"AlertIntervalCount": 1}`)
+
+	runPasses(t, 3)
+
+	if desired := *group.Group.DesiredCapacity; desired <= 2 {
+		t.Fatalf("desired capacity = %d, want more than 2", desired)
+	}
+	alerts := ecsClusters[clusterArn].Alerts
+	if len(alerts) != 1 || alerts[0].Type != alert.ScaleUp || alerts[0].Status != alert.Completed {
+		t.Fatalf("alerts = %v, want one Completed ScaleUp alert", alerts)
+	}
+}
+
+func TestProcessLeavesBalancedClusterAlone(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	cluster := fake.AddCluster("web")
+	clusterArn := *cluster.Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 5, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 2, 512, 1024)
+	newTestManager(t, fake, `{"AlertIntervalCount": 1}`)
+
+	runPasses(t, 3)
+
+	if desired := *group.Group.DesiredCapacity; desired != 2 {
+		t.Fatalf("desired capacity = %d, want 2", desired)
+	}
+	if alerts := ecsClusters[clusterArn].Alerts; len(alerts) != 0 {
+		t.Fatalf("alerts = %v, want none", alerts)
+	}
+}
+
+// newTestElector makes this replica the leader of a memory lease store on a
+// settable clock
+func newTestElector(t *testing.T, now *time.Time) *leader.MemoryLeaseStore {
+	t.Helper()
+	store := leader.NewMemoryLeaseStore()
+	store.Now = func() time.Time { return *now }
+	elector = leader.NewElector(store, "ecs-manager", "a", 15*time.Second)
+	elector.Now = store.Now
+	if leading, err := elector.Campaign(); err != nil || !leading {
+		t.Fatalf("campaign = %t, %v, want leading", leading, err)
+	}
+	return store
+}
+
+func TestPerformStopsOnceLeaseIsLost(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	newTestManager(t, fake, `{}`)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newTestElector(t, &now)
+	ecsCluster := &ECSCluster{Config: config.Get()}
+	scaleUp := alert.NewAlert(alert.ScaleUp, alert.Resources, "arn:aws:ecs:us-west-2:123456789012:cluster/web", "")
+
+	ran := 0
+	run := func() error {
+		ran++
+		return nil
+	}
+	if performed, err := ecsCluster.perform(action.IncreaseCapacity, scaleUp, "", "test", run); !performed || err != nil {
+		t.Fatalf("perform while leading = %t, %v, want performed", performed, err)
+	}
+
+	//the pass stalls past the lease and another replica takes over
+	now = now.Add(20 * time.Second)
+	if _, err := store.Acquire("ecs-manager", "b", 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if performed, err := ecsCluster.perform(action.IncreaseCapacity, scaleUp, "", "test", run); performed || err != errLeadershipLost {
+		t.Fatalf("perform after takeover = %t, %v, want errLeadershipLost", performed, err)
+	}
+	if ran != 1 {
+		t.Fatalf("action ran %d times, want 1", ran)
+	}
+}
+
+func TestTakeoverKeepsClusterState(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	newTestManager(t, fake, `{}`)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := leader.NewMemoryLeaseStore()
+	store.Now = func() time.Time { return now }
+	if _, err := store.Acquire("ecs-manager", "b", 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	elector = leader.NewElector(store, "ecs-manager", "a", 15*time.Second)
+	elector.Now = store.Now
+
+	clusterArn := "arn:aws:ecs:us-west-2:123456789012:cluster/web"
+	lastScheduleCheck := now.Add(-5 * time.Second)
+	breaker := circuit.NewBreaker(3, time.Minute)
+	ecsClusters[clusterArn] = &ECSCluster{
+		Alerts:            []*alert.Alert{alert.NewAlert(alert.ScaleUp, alert.Resources, clusterArn, "")},
+		LastScheduleCheck: lastScheduleCheck,
+		Breaker:           breaker,
+	}
+	if campaign() {
+		t.Fatal("campaign won a lease held by another replica")
+	}
+
+	now = now.Add(20 * time.Second)
+	if !campaign() {
+		t.Fatal("campaign lost an expired lease")
+	}
+	ecsCluster := ecsClusters[clusterArn]
+	if ecsCluster == nil || ecsCluster.Breaker != breaker || !ecsCluster.LastScheduleCheck.Equal(lastScheduleCheck) {
+		t.Fatalf("cluster state after takeover = %+v, want the breaker and LastScheduleCheck kept", ecsCluster)
+	}
+	if len(ecsCluster.Alerts) != 0 {
+		t.Fatalf("alerts after takeover = %v, want the observed ones dropped", ecsCluster.Alerts)
+	}
+}
+
+func TestStandbySkipsSchedules(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	cluster := fake.AddCluster("web")
+	clusterArn := *cluster.Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 5, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 2, 512, 1024)
+	due := time.Now().UTC().Add(-time.Minute).Format("15:04")
+	newTestManager(t, fake, `{"AlertIntervalCount": 1, "Schedules": ["`+due+` scale to 3"]}`)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := leader.NewMemoryLeaseStore()
+	store.Now = func() time.Time { return now }
+	if _, err := store.Acquire("ecs-manager", "b", 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	elector = leader.NewElector(store, "ecs-manager", "a", 15*time.Second)
+	elector.Now = store.Now
+
+	runPasses(t, 3)
+	for _, observed := range ecsClusters[clusterArn].Alerts {
+		if observed.Trigger == alert.Schedule {
+			t.Fatalf("standby raised %v", observed)
+		}
+	}
+
+	//after taking over, the schedule due within ScheduleCatchUpSeconds runs once
+	now = now.Add(20 * time.Second)
+	runPasses(t, 2)
+	scheduled := 0
+	for _, raised := range ecsClusters[clusterArn].Alerts {
+		if raised.Trigger == alert.Schedule {
+			scheduled++
+		}
+	}
+	if scheduled != 1 {
+		t.Fatalf("alerts = %v, want one Schedule alert", ecsClusters[clusterArn].Alerts)
+	}
+}
+
+// recordingNotifier keeps the events sent to it
+type recordingNotifier struct {
+	mutex  sync.Mutex
+	events []notify.Event
+}
+
+func (r *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func (r *recordingNotifier) Notify(event *notify.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func TestCancelledAlertsAreNotified(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	cluster := fake.AddCluster("web")
+	clusterArn := *cluster.Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 5, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 2, 512, 1024)
+	newTestManager(t, fake, `{"AlertIntervalCount": 3}`)
+	runPasses(t, 1)
+
+	notifier := &recordingNotifier{}
+	sink, err := notify.NewSink(notifier, []string{"*:Cancelled"}, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecsClusters[clusterArn].Sinks = []*notify.Sink{sink}
+	//raised on an earlier, busier pass
+	scaleUp := alert.NewAlert(alert.ScaleUp, alert.Resources, clusterArn, "")
+	scaleUp.Transition(alert.Pending, "raised")
+	ecsClusters[clusterArn].Alerts = []*alert.Alert{scaleUp}
+
+	runPasses(t, 1)
+	if !notify.Wait(time.Second) {
+		t.Fatal("notifications still running")
+	}
+
+	if scaleUp.Status != alert.Cancelled {
+		t.Fatalf("alert status = %s, want Cancelled", scaleUp.Status)
+	}
+	if len(notifier.events) != 1 || notifier.events[0].Type != "ScaleUp" || notifier.events[0].From != "Pending" || notifier.events[0].To != "Cancelled" {
+		t.Fatalf("events = %+v, want one ScaleUp Pending->Cancelled event", notifier.events)
+	}
+}
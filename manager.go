@@ -1,10 +1,17 @@
 package main
 
 import (
-	"regexp"
+	"math"
+	"github.com/sd-charris/ecs-manager/action"
 	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/check"
+	"github.com/sd-charris/ecs-manager/circuit"
 	"github.com/sd-charris/ecs-manager/config"
 	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sd-charris/ecs-manager/notify"
+	"github.com/sd-charris/ecs-manager/schedule"
+	"github.com/go-errors/errors"
 	"github.com/sirupsen/logrus"
 	"sort"
 	"time"
@@ -13,17 +20,45 @@ import (
 type ECSCluster struct {
 	ClusterDetails *ecs.ClusterDetails
 	Alerts         []*alert.Alert
+	Config         *config.Config
+	// LastScheduleCheck is when the cluster's schedules were last checked
+	LastScheduleCheck time.Time
+	// CheckResults holds the outcome of each check on the last pass
+	CheckResults []*check.Result
+	// Sinks are notified of alert status changes made by reconcileAlerts
+	Sinks []*notify.Sink
+	// Breaker pauses actions on the cluster after consecutive failed passes
+	Breaker *circuit.Breaker
+	// LastError is the error of the last failed pass, empty once a pass succeeds
+	LastError string
+	// actions holds the actions taken for each alert during reconcileAlerts
+	actions map[*alert.Alert][]*action.Action
+	// actionErr is the first action that failed during the current pass
+	actionErr error
 }
 
+// errCircuitOpen is returned by perform while the cluster's circuit breaker is open
+var errCircuitOpen = errors.New("circuit breaker open, action not started")
+
+// errLeadershipLost is returned by perform once another replica holds the lease
+var errLeadershipLost = errors.New("leadership lost, action not started")
+
+// errDryRun is returned by perform for an action only planned, so the alert
+// stays Pending instead of completing with nothing changed
+var errDryRun = errors.New("dry run, action only planned")
+
+// schedules caches the parsed Schedules config entries by their text
+var schedules = make(map[string]*schedule.Schedule)
+
 func round(x, unit float64) float64 {
 	return float64(int64(x/unit+0.5)) * unit
 }
 
-func clusterResourcesSupportUpScale(cluster *ecs.ClusterDetails) bool {
+func clusterResourcesSupportUpScale(cluster *ecs.ClusterDetails, settings *config.Config) bool {
 	boxSize := cluster.ContainerInstances[0].TotalCPU
 	newTotal := cluster.TotalCPU + *boxSize
 	percentUtilization := round(1-(float64(cluster.TotalRemainingCPU + *boxSize)/float64(newTotal)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceRemoveThresholdPercent") {
+	if percentUtilization > settings.ResourceRemoveThresholdPercent {
 		if *cluster.AutoScalingGroup.DesiredInstanceCount >= *cluster.AutoScalingGroup.MaxInstanceCount {
 			logrus.Info("Autoscaling Maximum Instance Count Achieved")
 			return false
@@ -34,7 +69,7 @@ func clusterResourcesSupportUpScale(cluster *ecs.ClusterDetails) bool {
 	boxSize = cluster.ContainerInstances[0].TotalMemory
 	newTotal = cluster.TotalMemory + *boxSize
 	percentUtilization = round(1-(float64(cluster.TotalRemainingMemory + *boxSize)/float64(newTotal)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceRemoveThresholdPercent") {
+	if percentUtilization > settings.ResourceRemoveThresholdPercent {
 		if *cluster.AutoScalingGroup.DesiredInstanceCount >= *cluster.AutoScalingGroup.MaxInstanceCount {
 			logrus.Info("Autoscaling Maximum Instance Count Achieved")
 			return false
@@ -44,18 +79,18 @@ func clusterResourcesSupportUpScale(cluster *ecs.ClusterDetails) bool {
 	return false
 }
 
-func clusterResourcesSupportDownScale(cluster *ecs.ClusterDetails) bool {
+func clusterResourcesSupportDownScale(cluster *ecs.ClusterDetails, settings *config.Config) bool {
 	boxSize := cluster.ContainerInstances[0].TotalCPU
 	newTotal := cluster.TotalCPU - *boxSize
 	percentUtilization := round(1-(float64(cluster.TotalRemainingCPU - *boxSize)/float64(newTotal)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceAddThresholdPercent") {
+	if percentUtilization > settings.ResourceAddThresholdPercent {
 		return false
 	}
 
 	boxSize = cluster.ContainerInstances[0].TotalMemory
 	newTotal = cluster.TotalMemory - *boxSize
 	percentUtilization = round(1-(float64(cluster.TotalRemainingMemory - *boxSize)/float64(newTotal)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceAddThresholdPercent") {
+	if percentUtilization > settings.ResourceAddThresholdPercent {
 		return false
 	}
 
@@ -63,24 +98,37 @@ func clusterResourcesSupportDownScale(cluster *ecs.ClusterDetails) bool {
 		logrus.Info("Autoscaling Minimum Instance Count Achieved")
 		return false
 	}
+
+	//the aggregate may have room while the tasks still do not fit anywhere else
+	containerInstanceArn := cluster.DrainCandidate(nil)
+	simulation := cluster.SimulateDrain(containerInstanceArn)
+	if !simulation.Fits() {
+		logrus.WithFields(logrus.Fields{
+			"ClusterArn":           *cluster.ClusterArn,
+			"ContainerInstanceArn": *containerInstanceArn,
+			"Reason":               simulation.Reason,
+		}).Info("Drain Candidate Tasks Cannot Be Re-placed")
+		return false
+	}
 	return true
 }
 
-func checkClusterResources(cluster *ecs.ClusterDetails) []*alert.Alert {
+func checkClusterResources(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert {
 	alerts := make([]*alert.Alert, 0)
 
 	//calculate the aggregate percentage of cpu utilization
 	percentUtilization := round(1-(float64(cluster.TotalRemainingCPU)/float64(cluster.TotalCPU)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceAddThresholdPercent") {
-		if clusterResourcesSupportUpScale(cluster) {
+	metrics.SetClusterResource(*cluster.ClusterArn, "cpu", cluster.TotalCPU, cluster.TotalRemainingCPU, percentUtilization)
+	if percentUtilization > settings.ResourceAddThresholdPercent {
+		if clusterResourcesSupportUpScale(cluster, settings) {
 			alert := alert.NewAlert(alert.ScaleUp, alert.Resources, *cluster.ClusterArn , "")
 			logrus.WithFields(logrus.Fields{
 				"Alert":    alert,
 			}).Info("Creating Alert")
 			alerts = append(alerts, alert)
 		}
-	} else if percentUtilization < *config.GetConfigValueAsFloat64("ResourceRemoveThresholdPercent") {
-		if clusterResourcesSupportDownScale(cluster) {
+	} else if percentUtilization < settings.ResourceRemoveThresholdPercent {
+		if clusterResourcesSupportDownScale(cluster, settings) {
 			alert := alert.NewAlert(alert.ScaleDown, alert.Resources, *cluster.ClusterArn , "")
 			logrus.WithFields(logrus.Fields{
 				"Alert":    alert,
@@ -91,16 +139,17 @@ func checkClusterResources(cluster *ecs.ClusterDetails) []*alert.Alert {
 
 	//calculate the aggregate percentage of memory utilization
 	percentUtilization = round(1-(float64(cluster.TotalRemainingMemory)/float64(cluster.TotalMemory)), .01)
-	if percentUtilization > *config.GetConfigValueAsFloat64("ResourceAddThresholdPercent") {
-		if clusterResourcesSupportUpScale(cluster) {
+	metrics.SetClusterResource(*cluster.ClusterArn, "memory", cluster.TotalMemory, cluster.TotalRemainingMemory, percentUtilization)
+	if percentUtilization > settings.ResourceAddThresholdPercent {
+		if clusterResourcesSupportUpScale(cluster, settings) {
 			alert := alert.NewAlert(alert.ScaleUp, alert.Resources, *cluster.ClusterArn , "")
 			logrus.WithFields(logrus.Fields{
 				"Alert":    alert,
 			}).Info("Creating Alert")
 			alerts = append(alerts, alert)
 		}
-	} else if percentUtilization < *config.GetConfigValueAsFloat64("ResourceRemoveThresholdPercent") {
-		if clusterResourcesSupportDownScale(cluster) {
+	} else if percentUtilization < settings.ResourceRemoveThresholdPercent {
+		if clusterResourcesSupportDownScale(cluster, settings) {
 			alert := alert.NewAlert(alert.ScaleDown, alert.Resources, *cluster.ClusterArn , "")
 			logrus.WithFields(logrus.Fields{
 				"Alert":    alert,
@@ -112,9 +161,9 @@ func checkClusterResources(cluster *ecs.ClusterDetails) []*alert.Alert {
 	return alerts
 }
 
-func checkAllInstancesState(cluster *ecs.ClusterDetails) []*alert.Alert {
+func checkAllInstancesState(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert {
 	alerts := make([]*alert.Alert, 0)
-	var instanceAge = int(*config.GetConfigValueAsInt64("InstanceMaxAgeDays"))
+	var instanceAge = int(settings.InstanceMaxAgeDays)
 
 	for _, clusterInstance := range cluster.ContainerInstances {
 		expiredDate := clusterInstance.RegisteredDate.AddDate(0, 0, instanceAge)
@@ -141,34 +190,421 @@ func checkAllInstancesState(cluster *ecs.ClusterDetails) []*alert.Alert {
 	return alerts
 }
 
-func checkServicesDesiredCount(cluster *ecs.ClusterDetails) []*alert.Alert {
+// checkPendingTasks sizes a ScaleUp for the tasks the cluster cannot start
+func checkPendingTasks(cluster *ecs.ClusterDetails, settings *config.Config) []*alert.Alert {
 	alerts := make([]*alert.Alert, 0)
-	r, _ := regexp.Compile(".*(insufficient).*(available).*") //need to find a better way to identify if there is a provisioning limit issue
+	threshold := time.Now().Add(-time.Duration(settings.PendingTaskThresholdSeconds) * time.Second)
+
+	var neededCPU, neededMemory int64
+	for _, task := range cluster.Tasks {
+		if task.Status == nil || (*task.Status != "PROVISIONING" && *task.Status != "PENDING") {
+			continue
+		}
+		if task.CreatedAt == nil || task.CreatedAt.After(threshold) {
+			continue
+		}
+		neededCPU += int64(*task.CPU)
+		neededMemory += int64(*task.Memory)
+	}
 
+	var missingCPU, missingMemory int64
 	for _, service := range cluster.Services {
-		if *service.DesiredTaskCount > (*service.CurrentTaskCount + *service.PendingTaskCount) {
-			if len(service.Events) > 0 {
-				lastMessage := *service.Events[0].Message
-				if r.MatchString(lastMessage) {
-					alert := alert.NewAlert(alert.ScaleUp, alert.Service, *cluster.ClusterArn , "")
-					logrus.WithFields(logrus.Fields{
-						"Alert":    alert,
-					}).Info("Creating Alert")
-					alerts = append(alerts, alert)
-				}
+		missing := *service.DesiredTaskCount - *service.CurrentTaskCount - *service.PendingTaskCount
+		if missing <= 0 {
+			continue
+		}
+		var serviceCPU, serviceMemory, running int64
+		for _, task := range cluster.Tasks {
+			if task.Group != nil && service.ServiceName != nil && *task.Group == "service:"+*service.ServiceName && task.Status != nil && *task.Status == "RUNNING" {
+				serviceCPU += int64(*task.CPU)
+				serviceMemory += int64(*task.Memory)
+				running++
 			}
 		}
+		//nothing to size the tasks by, and a service may just fail to start
+		if running == 0 {
+			continue
+		}
+		missingCPU += missing * serviceCPU / running
+		missingMemory += missing * serviceMemory / running
+	}
+	if missingCPU > cluster.TotalRemainingCPU {
+		neededCPU += missingCPU - cluster.TotalRemainingCPU
+	}
+	if missingMemory > cluster.TotalRemainingMemory {
+		neededMemory += missingMemory - cluster.TotalRemainingMemory
+	}
+
+	if neededCPU == 0 && neededMemory == 0 {
+		return alerts
+	}
+
+	count := instancesFor(cluster, float64(neededCPU), float64(neededMemory))
+	if count < 1 {
+		count = 1
+	}
+
+	if cluster.AutoScalingGroup == nil || *cluster.AutoScalingGroup.DesiredInstanceCount >= *cluster.AutoScalingGroup.MaxInstanceCount {
+		logrus.Info("Autoscaling Maximum Instance Count Achieved")
+		return alerts
 	}
+
+	alert := alert.NewAlert(alert.ScaleUp, alert.Service, *cluster.ClusterArn , "")
+	alert.InstanceCount = count
+	logrus.WithFields(logrus.Fields{
+		"Alert":        alert,
+		"NeededCPU":    neededCPU,
+		"NeededMemory": neededMemory,
+	}).Info("Creating Alert")
+	alerts = append(alerts, alert)
 	return alerts
 }
 
-func (ecsCluster *ECSCluster) reconcileAlerts() {
+func parseSchedule(text string) (*schedule.Schedule, error) {
+	if clusterSchedule, ok := schedules[text]; ok {
+		return clusterSchedule, nil
+	}
+	clusterSchedule, err := schedule.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	schedules[text] = clusterSchedule
+	return clusterSchedule, nil
+}
+
+// checkSchedules merges the schedules due in (since, until] into one alert
+func checkSchedules(cluster *ecs.ClusterDetails, settings *config.Config, since time.Time, until time.Time) []*alert.Alert {
+	alerts := make([]*alert.Alert, 0)
+	if cluster.AutoScalingGroup == nil {
+		return alerts
+	}
+
+	var capacity *alert.Capacity
+	for _, text := range settings.Schedules {
+		clusterSchedule, err := parseSchedule(text)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		if !clusterSchedule.Occurred(since, until) {
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"ClusterArn": *cluster.ClusterArn,
+			"Schedule":   text,
+		}).Info("Schedule Due")
+
+		if capacity == nil {
+			capacity = &alert.Capacity{}
+		}
+		if clusterSchedule.Min != nil {
+			capacity.Min = clusterSchedule.Min
+		}
+		if clusterSchedule.Max != nil {
+			capacity.Max = clusterSchedule.Max
+		}
+		if clusterSchedule.Desired != nil {
+			capacity.Desired = clusterSchedule.Desired
+		}
+	}
+	if capacity == nil {
+		return alerts
+	}
+
+	alertType := alert.ScaleUp
+	_, _, desired := scheduledCapacity(cluster.AutoScalingGroup, capacity)
+	if desired < *cluster.AutoScalingGroup.DesiredInstanceCount {
+		alertType = alert.ScaleDown
+	}
+	alert := alert.NewAlert(alertType, alert.Schedule, *cluster.ClusterArn, "")
+	alert.Capacity = capacity
+	logrus.WithFields(logrus.Fields{
+		"Alert":    alert,
+	}).Info("Creating Alert")
+	return append(alerts, alert)
+}
+
+// scheduledCapacity applies a Schedule alert's sizes to the group's current ones
+func scheduledCapacity(group *ecs.AutoScalingGroupDetails, capacity *alert.Capacity) (int64, int64, int64) {
+	min := *group.MinInstanceCount
+	max := *group.MaxInstanceCount
+	desired := *group.DesiredInstanceCount
+	if capacity.Min != nil {
+		min = *capacity.Min
+	}
+	if capacity.Max != nil {
+		max = *capacity.Max
+	}
+	if capacity.Desired != nil {
+		desired = *capacity.Desired
+		if capacity.Min == nil && min > desired {
+			min = desired
+		}
+		if capacity.Max == nil && max < desired {
+			max = desired
+		}
+	}
+
+	if min > max {
+		if capacity.Max == nil {
+			max = min
+		} else {
+			min = max
+		}
+	}
+	if desired < min {
+		desired = min
+	}
+	if desired > max {
+		desired = max
+	}
+	return min, max, desired
+}
+
+// utilization is the share of total taken by used, full when total is empty
+func utilization(used int64, total int64) float64 {
+	if total <= 0 {
+		return 1
+	}
+	return round(float64(used)/float64(total), .01)
+}
+
+// instancesFor returns how many instances of the cluster's average size hold
+// the given cpu and memory
+func instancesFor(cluster *ecs.ClusterDetails, cpu float64, memory float64) int64 {
+	instanceCount := float64(len(cluster.ContainerInstances))
+	if instanceCount == 0 || cluster.TotalCPU <= 0 || cluster.TotalMemory <= 0 {
+		return 0
+	}
+	needed := math.Max(cpu/(float64(cluster.TotalCPU)/instanceCount), memory/(float64(cluster.TotalMemory)/instanceCount))
+	return int64(math.Ceil(needed))
+}
+
+// instancesToAdd sizes a scale up to bring utilization back under the threshold
+func instancesToAdd(cluster *ecs.ClusterDetails, settings *config.Config, minimum int64) int64 {
+	count := int64(1)
+	if minimum > count {
+		count = minimum
+	}
+	neededCPU := float64(cluster.TotalCPU-cluster.TotalRemainingCPU)/settings.ResourceAddThresholdPercent - float64(cluster.TotalCPU)
+	neededMemory := float64(cluster.TotalMemory-cluster.TotalRemainingMemory)/settings.ResourceAddThresholdPercent - float64(cluster.TotalMemory)
+	if needed := instancesFor(cluster, neededCPU, neededMemory); needed > count {
+		count = needed
+	}
+
+	if count > settings.ScaleUpMaxStep {
+		count = settings.ScaleUpMaxStep
+	}
+	if room := *cluster.AutoScalingGroup.MaxInstanceCount - *cluster.AutoScalingGroup.DesiredInstanceCount; count > room {
+		count = room
+	}
+	return count
+}
+
+// scaleDownCandidates picks the instances a resource scale down drains, none
+// when the group is at its minimum or the first one's tasks fit nowhere else
+func scaleDownCandidates(cluster *ecs.ClusterDetails, settings *config.Config, containerInstanceArn *string) []*ecs.ContainerInstance {
+	limit := settings.ScaleDownMaxStep
+	if room := *cluster.AutoScalingGroup.DesiredInstanceCount - *cluster.AutoScalingGroup.MinInstanceCount; room < limit {
+		limit = room
+	}
+	if limit < 1 {
+		return nil
+	}
+
+	candidates := cluster.DrainCandidates(containerInstanceArn)
+	if len(candidates) == 0 {
+		return nil
+	}
+	if !cluster.SimulateDrain(candidates[0].ContainerInstanceArn).Fits() {
+		return nil
+	}
+	usedCPU := cluster.TotalCPU - cluster.TotalRemainingCPU
+	usedMemory := cluster.TotalMemory - cluster.TotalRemainingMemory
+	totalCPU := cluster.TotalCPU - *candidates[0].TotalCPU
+	totalMemory := cluster.TotalMemory - *candidates[0].TotalMemory
+	selected := []*ecs.ContainerInstance{candidates[0]}
+
+	for _, candidate := range candidates[1:] {
+		if int64(len(selected)) >= limit {
+			break
+		}
+		if utilization(usedCPU, totalCPU) >= settings.ResourceRemoveThresholdPercent && utilization(usedMemory, totalMemory) >= settings.ResourceRemoveThresholdPercent {
+			break
+		}
+		remainingCPU := totalCPU - *candidate.TotalCPU
+		remainingMemory := totalMemory - *candidate.TotalMemory
+		if utilization(usedCPU, remainingCPU) > settings.ResourceAddThresholdPercent || utilization(usedMemory, remainingMemory) > settings.ResourceAddThresholdPercent {
+			break
+		}
+		if !cluster.SimulateDrain(instanceArns(append(selected, candidate))...).Fits() {
+			break
+		}
+		selected = append(selected, candidate)
+		totalCPU = remainingCPU
+		totalMemory = remainingMemory
+	}
+	return selected
+}
+
+// perform records a mutating action taken for an alert and runs it, it returns
+// true once the action was sent to AWS
+func (ecsCluster *ECSCluster) perform(actionType action.Type, alertItem *alert.Alert, containerInstanceArn string, reason string, run func() error) (bool, error) {
+	if shuttingDown() && len(ecsCluster.actions[alertItem]) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"Action": actionType,
+			"Alert":  alertItem,
+		}).Warn("Skipped Action (shutting down)")
+		return false, errShuttingDown
+	}
+	if ecsCluster.Breaker != nil && !ecsCluster.Breaker.Allow(time.Now()) {
+		logrus.WithFields(logrus.Fields{
+			"Action": actionType,
+			"Alert":  alertItem,
+		}).Warn("Skipped Action (circuit open)")
+		return false, errCircuitOpen
+	}
+	planOnly := dryRun || ecsCluster.Config.DryRun
+	if !planOnly && !holdsLeadership() {
+		logrus.WithFields(logrus.Fields{
+			"Action": actionType,
+			"Alert":  alertItem,
+		}).Warn("Skipped Action (leadership lost)")
+		return false, errLeadershipLost
+	}
+	plannedAction := action.NewAction(actionType, alertItem, containerInstanceArn, reason, planOnly)
+	defer action.Record(plannedAction)
+	if ecsCluster.actions != nil {
+		ecsCluster.actions[alertItem] = append(ecsCluster.actions[alertItem], plannedAction)
+	}
+
+	if planOnly {
+		alertItem.RecordAction(actionType.String(), reason+" (dry run)", "")
+		logrus.WithFields(logrus.Fields{
+			"Action": plannedAction,
+			"Alert":  alertItem,
+		}).Info("Planned Action (dry run)")
+		metrics.ActionPlanned(plannedAction.ClusterArn, actionType.String())
+		return false, errDryRun
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Action": plannedAction,
+		"Alert":  alertItem,
+	}).Info("Performing Action")
+	started := time.Now()
+	err := run()
+	metrics.ActionPerformed(plannedAction.ClusterArn, actionType.String(), time.Since(started), err)
+	if err != nil {
+		plannedAction.Error = err.Error()
+		alertItem.RecordAction(actionType.String(), reason, err.Error())
+		class := ecs.Classify(err)
+		logrus.WithFields(logrus.Fields{
+			"Action": plannedAction,
+			"Class":  class,
+		}).Error(err)
+		//a vanished instance or group is not a reason to stop acting on the cluster
+		if ecsCluster.actionErr == nil && class != ecs.ErrorNotFound {
+			ecsCluster.actionErr = err
+		}
+		//retrying a rejected request will not fix it
+		if class == ecs.ErrorOther {
+			transition(alertItem, alert.Failed, err.Error())
+		}
+		return false, err
+	}
+	alertItem.RecordAction(actionType.String(), reason, "")
+	return true, nil
+}
+
+// transition moves an alert to status, logging the changes its type does not
+// allow instead of making them
+func transition(alertItem *alert.Alert, status alert.Status, reason string) {
+	err := alertItem.Transition(status, reason)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Alert": alertItem,
+		}).Error(err)
+	}
+}
+
+// recordPass feeds a pass's outcome, nil on success, to the circuit breaker
+func (ecsCluster *ECSCluster) recordPass(clusterArn string, err error) {
+	settings := ecsCluster.Config
+	if settings == nil {
+		settings = config.Get()
+	}
+	threshold := int(settings.CircuitBreakerThreshold)
+	cooldown := time.Duration(settings.CircuitBreakerCooldownSeconds) * time.Second
+	if ecsCluster.Breaker == nil {
+		ecsCluster.Breaker = circuit.NewBreaker(threshold, cooldown)
+	}
+	ecsCluster.Breaker.Configure(threshold, cooldown)
+
+	now := time.Now()
+	previous := ecsCluster.Breaker.State(now)
+	if err == nil {
+		ecsCluster.Breaker.Success(now)
+		ecsCluster.LastError = ""
+	} else {
+		class := ecs.Classify(err)
+		metrics.ClusterFailed(clusterArn, class.String())
+		ecsCluster.LastError = err.Error()
+		if class == ecs.ErrorAuth {
+			ecsCluster.Breaker.Trip(now)
+		} else {
+			ecsCluster.Breaker.Failure(now)
+		}
+	}
+
+	current := ecsCluster.Breaker.State(now)
+	metrics.SetCircuitState(clusterArn, int(current))
+	if current != previous {
+		logrus.WithFields(logrus.Fields{
+			"ClusterArn": clusterArn,
+			"Previous":   previous,
+			"State":      current,
+			"Failures":   ecsCluster.Breaker.Failures(),
+			"Cooldown":   cooldown,
+		}).Warn("Circuit Breaker Changed")
+	}
+}
+
+// reconcileAlerts acts on the alerts, previous reports the cancelled ones too
+func (ecsCluster *ECSCluster) reconcileAlerts(previous map[*alert.Alert]alert.Status) {
+	alerts := append([]*alert.Alert{}, ecsCluster.Alerts...)
+	statuses := make(map[*alert.Alert]alert.Status)
+	for _, alertItem := range alerts {
+		statuses[alertItem] = alertItem.Status
+	}
+	cancelled := make([]*alert.Alert, 0)
+	for alertItem, status := range previous {
+		if _, ok := statuses[alertItem]; !ok && alertItem.Status != status {
+			cancelled = append(cancelled, alertItem)
+			statuses[alertItem] = status
+		}
+	}
+	sort.Slice(cancelled, func(i, j int) bool {
+		return cancelled[i].AlertDate.Before(cancelled[j].AlertDate)
+	})
+	alerts = append(cancelled, alerts...)
+	ecsCluster.actions = make(map[*alert.Alert][]*action.Action)
+	defer func() {
+		//alerts raised during the pass, such as escalations, are reported too
+		for _, alertItem := range ecsCluster.Alerts {
+			if _, ok := statuses[alertItem]; !ok {
+				alerts = append(alerts, alertItem)
+			}
+		}
+		ecsCluster.notifyTransitions(alerts, statuses)
+	}()
 
-	alertIntervalCount := config.GetConfigValueAsInt64("AlertIntervalCount")
-	alertCoolDownIntervalCount := config.GetConfigValueAsInt64("AlertCooldownIntervalCount")
+	alertIntervalCount := ecsCluster.Config.AlertIntervalCount
+	alertCoolDownIntervalCount := ecsCluster.Config.AlertCooldownIntervalCount
 	scaleUpAlerts := make([]*alert.Alert, 0)
 	scaleDownAlerts := make([]*alert.Alert, 0)
 	retireAlerts := make([]*alert.Alert, 0)
+	escalationAlerts := make([]*alert.Alert, 0)
 
 	//order by date
 	sort.Slice(ecsCluster.Alerts, func(i, j int) bool {
@@ -188,65 +624,74 @@ func (ecsCluster *ECSCluster) reconcileAlerts() {
 		if alertItem.Type == alert.Retire {
 			retireAlerts = append(retireAlerts, alertItem)
 		}
+
+		if alertItem.Type == alert.Escalation {
+			escalationAlerts = append(escalationAlerts, alertItem)
+		}
 	}
 
+	//escalate timed out drains before the forced removals of DrainTimeoutPolicy
+	escalationAlerts = ecsCluster.reconcileEscalations(escalationAlerts, append(append([]*alert.Alert{}, scaleDownAlerts...), retireAlerts...))
+
 	// if there a scale up event
 	if len(scaleUpAlerts) > 0 {
 		currentScaleUpAlert := scaleUpAlerts[0]
-		if currentScaleUpAlert.Status == alert.Pending && currentScaleUpAlert.EventCount > *alertIntervalCount {
-			ecsCluster.ClusterDetails.IncreaseClusterCapacity()
-			currentScaleUpAlert.Status = alert.InProgress
-			currentScaleUpAlert.EventCount = 0
+		if currentScaleUpAlert.Status == alert.Pending && currentScaleUpAlert.Trigger == alert.Schedule {
+			min, max, desired := scheduledCapacity(ecsCluster.ClusterDetails.AutoScalingGroup, currentScaleUpAlert.Capacity)
+			performed, _ := ecsCluster.perform(action.SetCapacity, currentScaleUpAlert, "", "scheduled capacity change", func() error {
+				return ecsCluster.ClusterDetails.SetClusterCapacity(&min, &max, &desired)
+			})
+			if performed {
+				transition(currentScaleUpAlert, alert.InProgress, "scheduled capacity set")
+			}
+		} else if currentScaleUpAlert.Status == alert.Pending && currentScaleUpAlert.EventCount > alertIntervalCount {
+			count := instancesToAdd(ecsCluster.ClusterDetails, ecsCluster.Config, currentScaleUpAlert.InstanceCount)
+			if count < 1 {
+				transition(currentScaleUpAlert, alert.Completed, "Auto Scaling group at its maximum size")
+			} else {
+				performed, _ := ecsCluster.perform(action.IncreaseCapacity, currentScaleUpAlert, "", "scale up alert pending longer than AlertIntervalCount", func() error {
+					return ecsCluster.ClusterDetails.IncreaseClusterCapacity(count)
+				})
+				if performed {
+					transition(currentScaleUpAlert, alert.InProgress, "capacity increased")
+				}
+			}
 		} else if currentScaleUpAlert.Status == alert.InProgress {
 			if int64(len(ecsCluster.ClusterDetails.ContainerInstances)) == *ecsCluster.ClusterDetails.AutoScalingGroup.DesiredInstanceCount {
-				currentScaleUpAlert.Status = alert.Completed
-				currentScaleUpAlert.EventCount = 0
+				transition(currentScaleUpAlert, alert.Completed, "instances registered")
 			} else {
 				logrus.Info("Still adding instances")
 			}
-		} else if currentScaleUpAlert.Status == alert.Completed && currentScaleUpAlert.EventCount > *alertCoolDownIntervalCount {
+		} else if currentScaleUpAlert.Status.Final() && currentScaleUpAlert.EventCount > alertCoolDownIntervalCount {
 			scaleUpAlerts = alert.DeleteAlertFromArray(scaleUpAlerts, 0)
 		}
 	} else if len(scaleDownAlerts) > 0 {
 		currentScaleDownAlerts := scaleDownAlerts[0]
-		if currentScaleDownAlerts.Status == alert.Pending && currentScaleDownAlerts.EventCount > *alertIntervalCount {
+		if currentScaleDownAlerts.Status == alert.Pending && currentScaleDownAlerts.Trigger == alert.Schedule {
+			ecsCluster.reconcileScheduledScaleDown(currentScaleDownAlerts)
+		} else if currentScaleDownAlerts.Status == alert.Pending && currentScaleDownAlerts.EventCount > alertIntervalCount {
 			var containerInstanceArn *string
 			if len(retireAlerts) > 0 {
 				currentRetireAlert := retireAlerts[0]
 				containerInstanceArn = &currentRetireAlert.ContainerInstanceArn
 			}
-			res, _ := ecsCluster.ClusterDetails.DrainClusterInstance(containerInstanceArn)
-			currentScaleDownAlerts.ContainerInstanceArn = *res
-			currentScaleDownAlerts.Status = alert.InProgress
-			currentScaleDownAlerts.EventCount = 0
+			candidates := scaleDownCandidates(ecsCluster.ClusterDetails, ecsCluster.Config, containerInstanceArn)
+			ecsCluster.drainInstances(currentScaleDownAlerts, candidates, "scale down alert pending longer than AlertIntervalCount")
 
 		} else if currentScaleDownAlerts.Status == alert.InProgress {
-			containerInstance := ecsCluster.ClusterDetails.GetContainerInstance(&currentScaleDownAlerts.ContainerInstanceArn)
-			if containerInstance != nil && *containerInstance.RunningTasksCount == 0 {
-				ecsCluster.ClusterDetails.RemoveClusterInstance(containerInstance.ContainerInstanceArn)
-				currentScaleDownAlerts.Status = alert.Completed
-				currentScaleDownAlerts.EventCount = 0
-			} else {
-				logrus.Info("Still draining instances")
-			}
-		} else if currentScaleDownAlerts.Status == alert.Completed && currentScaleDownAlerts.EventCount > *alertCoolDownIntervalCount  {
+			ecsCluster.removeDrainedInstances(currentScaleDownAlerts)
+		} else if currentScaleDownAlerts.Status.Final() && currentScaleDownAlerts.EventCount > alertCoolDownIntervalCount  {
 			scaleDownAlerts = alert.DeleteAlertFromArray(scaleDownAlerts, 0)
 		}
 	} else if len(retireAlerts) > 0 {
 		currentRetireAlert := retireAlerts[0]
-		if currentRetireAlert.Status == alert.Pending && currentRetireAlert.EventCount > *alertIntervalCount {
-			ecsCluster.ClusterDetails.StandByClusterInstance(&currentRetireAlert.ContainerInstanceArn)
-			ecsCluster.ClusterDetails.IncreaseClusterCapacity()
-			currentRetireAlert.Status = alert.InProgress
-			currentRetireAlert.EventCount = 0
-		} else if currentRetireAlert.Status == alert.InProgress {
-			if int64(len(ecsCluster.ClusterDetails.ContainerInstances)) >= *ecsCluster.ClusterDetails.AutoScalingGroup.DesiredInstanceCount {
-				currentRetireAlert.Status = alert.Completed
-				currentRetireAlert.EventCount = 0
-			} else {
-				logrus.Info("Still adding instances")
+		if currentRetireAlert.Status == alert.InProgress || (currentRetireAlert.Status == alert.Pending && currentRetireAlert.EventCount > alertIntervalCount) {
+			surge := int64(1)
+			if currentRetireAlert.Trigger == alert.Image {
+				surge = ecsCluster.Config.AMIRefreshSurgeCount
 			}
-		} else if currentRetireAlert.Status == alert.Completed && currentRetireAlert.EventCount > *alertCoolDownIntervalCount {
+			ecsCluster.reconcileRetire(currentRetireAlert, surge)
+		} else if currentRetireAlert.Status.Final() && currentRetireAlert.EventCount > alertCoolDownIntervalCount {
 			retireAlerts = alert.DeleteAlertFromArray(retireAlerts, 0)
 		}
 	}
@@ -261,5 +706,169 @@ func (ecsCluster *ECSCluster) reconcileAlerts() {
 	if len(retireAlerts) > 0 {
 		response = append(response, retireAlerts...)
 	}
+	if len(escalationAlerts) > 0 {
+		response = append(response, escalationAlerts...)
+	}
 	ecsCluster.Alerts = response
 }
+
+// notifyTransitions sends the sinks an event for every alert whose status
+// changed from the one in statuses
+func (ecsCluster *ECSCluster) notifyTransitions(alerts []*alert.Alert, statuses map[*alert.Alert]alert.Status) {
+	actions := ecsCluster.actions
+	ecsCluster.actions = nil
+	if len(ecsCluster.Sinks) == 0 {
+		return
+	}
+
+	cluster := ecsCluster.ClusterDetails
+	for _, alertItem := range alerts {
+		if alertItem.Status == statuses[alertItem] {
+			continue
+		}
+		notify.Send(ecsCluster.Sinks, notify.Event{
+			ClusterArn:  *cluster.ClusterArn,
+			ClusterName: *cluster.ClusterName,
+			AccountId:   cluster.AccountId,
+			Region:      cluster.Region,
+			Type:        alertItem.Type.String(),
+			Trigger:     alertItem.Trigger.String(),
+			From:        statuses[alertItem].String(),
+			To:          alertItem.Status.String(),
+			DryRun:      dryRun || ecsCluster.Config.DryRun,
+			Alert:       *alertItem,
+			Actions:     actions[alertItem],
+			Time:        time.Now(),
+		})
+	}
+}
+
+// reconcileScheduledScaleDown drains instances one at a time before lowering the group
+func (ecsCluster *ECSCluster) reconcileScheduledScaleDown(scaleDownAlert *alert.Alert) {
+	cluster := ecsCluster.ClusterDetails
+	group := cluster.AutoScalingGroup
+	min, max, desired := scheduledCapacity(group, scaleDownAlert.Capacity)
+
+	if *group.DesiredInstanceCount <= desired || len(cluster.ContainerInstances) == 0 {
+		_, err := ecsCluster.perform(action.SetCapacity, scaleDownAlert, "", "scheduled capacity change", func() error {
+			return cluster.SetClusterCapacity(&min, &max, &desired)
+		})
+		if err == nil {
+			transition(scaleDownAlert, alert.Completed, "scheduled capacity set")
+		}
+		return
+	}
+
+	//keep room for the running instances until they are drained
+	if max < *group.DesiredInstanceCount {
+		max = *group.DesiredInstanceCount
+	}
+	_, err := ecsCluster.perform(action.SetCapacity, scaleDownAlert, "", "lower minimum before scheduled scale down", func() error {
+		return cluster.SetClusterCapacity(&min, &max, nil)
+	})
+	if err != nil {
+		return
+	}
+
+	count := *group.DesiredInstanceCount - desired
+	if count > ecsCluster.Config.ScaleDownMaxStep {
+		count = ecsCluster.Config.ScaleDownMaxStep
+	}
+	candidates := make([]*ecs.ContainerInstance, 0, count)
+	for _, candidate := range cluster.DrainCandidates(nil) {
+		if int64(len(candidates)) >= count || !cluster.SimulateDrain(instanceArns(append(candidates, candidate))...).Fits() {
+			break
+		}
+		candidates = append(candidates, candidate)
+	}
+	ecsCluster.drainInstances(scaleDownAlert, candidates, "scheduled scale down")
+}
+
+func instanceArns(instances []*ecs.ContainerInstance) []*string {
+	arns := make([]*string, 0, len(instances))
+	for _, instance := range instances {
+		arns = append(arns, instance.ContainerInstanceArn)
+	}
+	return arns
+}
+
+// drainInstances drains the given instances for a ScaleDown alert and tracks
+// the ones that started draining on the alert
+func (ecsCluster *ECSCluster) drainInstances(scaleDownAlert *alert.Alert, instances []*ecs.ContainerInstance, reason string) {
+	if len(instances) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"Alert": scaleDownAlert,
+		}).Info("No instance can be drained without stranding tasks")
+		return
+	}
+
+	drained := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		containerInstanceArn := instance.ContainerInstanceArn
+		performed, _ := ecsCluster.perform(action.DrainInstance, scaleDownAlert, *containerInstanceArn, reason, func() error {
+			_, err := ecsCluster.ClusterDetails.DrainClusterInstance(containerInstanceArn)
+			return err
+		})
+		if performed {
+			drained = append(drained, *containerInstanceArn)
+		}
+	}
+
+	if len(drained) > 0 {
+		scaleDownAlert.ContainerInstanceArn = drained[0]
+		scaleDownAlert.ContainerInstanceArns = drained
+		scaleDownAlert.DrainStartDate = time.Now()
+		transition(scaleDownAlert, alert.InProgress, "instances draining")
+	}
+}
+
+// removeDrainedInstances removes drained instances left with only daemon tasks
+func (ecsCluster *ECSCluster) removeDrainedInstances(scaleDownAlert *alert.Alert) {
+	//alerts saved before scale downs could drain several instances
+	if len(scaleDownAlert.ContainerInstanceArns) == 0 && scaleDownAlert.ContainerInstanceArn != "" {
+		scaleDownAlert.ContainerInstanceArns = []string{scaleDownAlert.ContainerInstanceArn}
+	}
+
+	timedOut := ecsCluster.drainTimedOut(scaleDownAlert)
+	policy := ecsCluster.Config.DrainTimeoutPolicy
+	draining := make([]string, 0, len(scaleDownAlert.ContainerInstanceArns))
+	for _, containerInstanceArn := range scaleDownAlert.ContainerInstanceArns {
+		containerInstance := ecsCluster.ClusterDetails.GetContainerInstance(&containerInstanceArn)
+		if containerInstance == nil {
+			logrus.WithFields(logrus.Fields{
+				"ClusterArn":           scaleDownAlert.ClusterArn,
+				"ContainerInstanceArn": containerInstanceArn,
+			}).Info("Drained instance left the cluster")
+			continue
+		}
+		reason := "drained instance has no running tasks"
+		if ecsCluster.ClusterDetails.DrainBlockingTaskCount(containerInstance) > 0 {
+			if !timedOut || policy != "terminate" {
+				if timedOut && policy == "stop-tasks" {
+					ecsCluster.stopStandaloneTasks(scaleDownAlert, containerInstance)
+				}
+				draining = append(draining, containerInstanceArn)
+				continue
+			}
+			reason = "drain exceeded DrainTimeoutSeconds"
+		}
+		_, err := ecsCluster.perform(action.RemoveInstance, scaleDownAlert, containerInstanceArn, reason, func() error {
+			return ecsCluster.ClusterDetails.RemoveClusterInstance(containerInstance.ContainerInstanceArn)
+		})
+		if err != nil {
+			draining = append(draining, containerInstanceArn)
+		}
+	}
+	scaleDownAlert.ContainerInstanceArns = draining
+
+	if len(draining) > 0 {
+		logrus.Info("Still draining instances")
+	} else if scaleDownAlert.Trigger == alert.Schedule {
+		//drain more instances until the scheduled size is reached
+		transition(scaleDownAlert, alert.Pending, "drained instances removed")
+	} else if scaleDownAlert.Type == alert.Retire {
+		logrus.Info("Retired instances removed")
+	} else {
+		transition(scaleDownAlert, alert.Completed, "drained instances removed")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/ecs"
+)
+
+// recentActions returns the recorded actions of a cluster, oldest first
+func recentActions(clusterArn string) []*action.Action {
+	actions := make([]*action.Action, 0)
+	for _, recorded := range action.Recent(0) {
+		if recorded.ClusterArn == clusterArn {
+			actions = append([]*action.Action{recorded}, actions...)
+		}
+	}
+	return actions
+}
+
+func TestDryRunPlansActionsWithoutAWS(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("dry-run").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("dry-run-asg", clusterArn, 1, 5, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 4, 460, 900)
+	newTestManager(t, fake, `{"AlertIntervalCount": 1}`)
+	dryRun = true
+
+	runPasses(t, 3)
+
+	if calls := countMutations(fake); calls != 0 {
+		t.Fatalf("%d mutating calls sent to AWS, want none", calls)
+	}
+	if desired := *group.Group.DesiredCapacity; desired != 2 {
+		t.Fatalf("desired capacity = %d, want 2", desired)
+	}
+	actions := recentActions(clusterArn)
+	if len(actions) == 0 || actions[0].Type != action.IncreaseCapacity || !actions[0].DryRun || actions[0].Reason == "" {
+		t.Fatalf("actions = %v, want a planned IncreaseCapacity action", actions)
+	}
+	//nothing changed, the alert waits for the manager to act
+	alerts := ecsClusters[clusterArn].Alerts
+	if len(alerts) != 1 || alerts[0].Type != alert.ScaleUp || alerts[0].Status != alert.Pending {
+		t.Fatalf("alerts = %v, want the ScaleUp alert left Pending", alerts)
+	}
+}
+
+// countMutations counts the calls that change AWS state
+func countMutations(fake *ecs.FakeAWS) int {
+	count := 0
+	for _, call := range fake.Calls {
+		switch call {
+		case "UpdateAutoScalingGroup", "UpdateContainerInstancesState", "DetachInstances", "TerminateInstances":
+			count++
+		}
+	}
+	return count
+}
+
+func TestCheckPendingTasks(t *testing.T) {
+	settings, err := config.Parse([]byte(`{"PendingTaskThresholdSeconds": 60}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		running         int
+		desired         int64
+		remainingCPU    int64
+		remainingMemory int64
+		pending         int
+		pendingAge      time.Duration
+		want            int64
+	}{
+		{"nothing missing", 2, 2, 0, 0, 0, 0, 0},
+		{"missing tasks fit", 2, 4, 1024, 2048, 0, 0, 0},
+		{"missing memory does not fit", 2, 4, 1024, 1024, 0, 0, 1},
+		{"missing cpu does not fit", 2, 6, 0, 8192, 0, 0, 2},
+		{"nothing running to size them by", 0, 2, 0, 0, 0, 0, 0},
+		{"pending past the threshold", 2, 2, 0, 0, 2, 2 * time.Minute, 1},
+		{"pending within the threshold", 2, 2, 0, 0, 2, 30 * time.Second, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := pendingTasksCluster(test.running, test.desired, test.remainingCPU, test.remainingMemory)
+			createdAt := time.Now().Add(-test.pendingAge)
+			for i := 0; i < test.pending; i++ {
+				cluster.Tasks = append(cluster.Tasks, &ecs.Task{
+					Status:    aws.String("PENDING"),
+					CPU:       aws.Int(512),
+					Memory:    aws.Int(1024),
+					CreatedAt: aws.Time(createdAt),
+				})
+			}
+
+			alerts := checkPendingTasks(cluster, settings)
+			if test.want == 0 {
+				if len(alerts) != 0 {
+					t.Fatalf("alerts = %v, want none", alerts)
+				}
+				return
+			}
+			if len(alerts) != 1 || alerts[0].Type != alert.ScaleUp || alerts[0].InstanceCount != test.want {
+				t.Fatalf("alerts = %v, want one ScaleUp alert for %d instances", alerts, test.want)
+			}
+		})
+	}
+}
+
+// pendingTasksCluster is two 1024 cpu, 2048 memory instances running the
+// given number of 512 cpu, 1024 memory tasks of a service
+func pendingTasksCluster(running int, desired int64, remainingCPU int64, remainingMemory int64) *ecs.ClusterDetails {
+	clusterArn := "arn:aws:ecs:us-west-2:123456789012:cluster/web"
+	min, max, desiredInstances := int64(1), int64(5), int64(2)
+	cluster := &ecs.ClusterDetails{
+		ClusterArn:           aws.String(clusterArn),
+		AutoScalingGroup:     &ecs.AutoScalingGroupDetails{MinInstanceCount: &min, MaxInstanceCount: &max, DesiredInstanceCount: &desiredInstances},
+		TotalCPU:             2048,
+		TotalMemory:          4096,
+		TotalRemainingCPU:    remainingCPU,
+		TotalRemainingMemory: remainingMemory,
+		Services: []*ecs.Service{{
+			ServiceName:      aws.String("api"),
+			DesiredTaskCount: aws.Int64(desired),
+			CurrentTaskCount: aws.Int64(int64(running)),
+			PendingTaskCount: aws.Int64(0),
+		}},
+	}
+	for i := 0; i < 2; i++ {
+		cluster.ContainerInstances = append(cluster.ContainerInstances, &ecs.ContainerInstance{
+			TotalCPU:    aws.Int64(1024),
+			TotalMemory: aws.Int64(2048),
+		})
+	}
+	for i := 0; i < running; i++ {
+		cluster.Tasks = append(cluster.Tasks, &ecs.Task{
+			Group:  aws.String("service:api"),
+			Status: aws.String("RUNNING"),
+			CPU:    aws.Int(512),
+			Memory: aws.Int(1024),
+		})
+	}
+	return cluster
+}
+
+func TestScaleDownCandidatesWithoutRoom(t *testing.T) {
+	settings, err := config.Parse([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every instance left the cluster between the check and the action
+	min, desired := int64(0), int64(2)
+	empty := &ecs.ClusterDetails{
+		ClusterArn:       aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/web"),
+		AutoScalingGroup: &ecs.AutoScalingGroupDetails{MinInstanceCount: &min, DesiredInstanceCount: &desired},
+	}
+	if selected := scaleDownCandidates(empty, settings, nil); selected != nil {
+		t.Fatalf("selected %v without instances, want nothing", selected)
+	}
+
+	//an idle cluster already at the group's minimum
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 2, 5, 2, 1024, 2048)
+	clusters, err := ecs.NewFakeClient(fake).GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected := scaleDownCandidates(clusters[0], settings, nil); selected != nil {
+		t.Fatalf("selected %v at the minimum size, want nothing", selected)
+	}
+}
+
+func TestScaleUpAtMaximumCompletes(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 2, 2, 1024, 2048)
+	newTestManager(t, fake, `{"AlertIntervalCount": 1}`)
+	clusters, err := ecs.NewFakeClient(fake).GetClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the group reached its maximum after the alert was raised
+	scaleUp := alert.NewAlert(alert.ScaleUp, alert.Resources, clusterArn, "")
+	scaleUp.Status = alert.Pending
+	scaleUp.EventCount = 2
+	ecsCluster := &ECSCluster{ClusterDetails: clusters[0], Alerts: []*alert.Alert{scaleUp}, Config: config.Get()}
+	ecsCluster.reconcileAlerts(map[*alert.Alert]alert.Status{scaleUp: alert.Pending})
+
+	if calls := countMutations(fake); calls != 0 {
+		t.Fatalf("%d mutating calls sent to AWS, want none", calls)
+	}
+	if scaleUp.Status != alert.Completed {
+		t.Fatalf("alert %v, want it Completed", scaleUp)
+	}
+}
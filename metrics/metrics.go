@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ecs_manager"
+
+var (
+	clusterResourceTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_resource_total",
+		Help:      "Registered cpu units or memory MiB across the cluster's container instances.",
+	}, []string{"cluster", "resource"})
+
+	clusterResourceRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_resource_remaining",
+		Help:      "Unreserved cpu units or memory MiB across the cluster's container instances.",
+	}, []string{"cluster", "resource"})
+
+	clusterUtilization = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_utilization_ratio",
+		Help:      "Share of the cluster's cpu or memory reserved by tasks, as evaluated against the scaling thresholds.",
+	}, []string{"cluster", "resource"})
+
+	clusterInstances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_container_instances",
+		Help:      "Container instances registered with the cluster.",
+	}, []string{"cluster"})
+
+	autoScalingGroupSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "autoscaling_group_size",
+		Help:      "Min, max and desired capacity of the auto scaling group backing the cluster.",
+	}, []string{"cluster", "autoscaling_group", "bound"})
+
+	alertsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_created_total",
+		Help:      "Alerts raised by the cluster checks.",
+	}, []string{"cluster", "type", "trigger"})
+
+	checkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "check_duration_seconds",
+		Help:      "Time spent evaluating each check against a cluster.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"check"})
+
+	checkEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_evaluations_total",
+		Help:      "Check evaluations by result: ok or failed.",
+	}, []string{"cluster", "check", "result"})
+
+	checkAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_alerts_total",
+		Help:      "Alerts returned by each check.",
+	}, []string{"cluster", "check"})
+
+	actions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "actions_total",
+		Help:      "Scaling actions by result: performed, planned (dry run) or failed.",
+	}, []string{"cluster", "action", "result"})
+
+	actionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "action_duration_seconds",
+		Help:      "Time spent performing scaling actions against AWS.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action"})
+
+	outdatedInstances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outdated_instances",
+		Help:      "Active container instances not running the AMI targeted by the image refresh.",
+	}, []string{"cluster"})
+
+	clusterFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cluster_failures_total",
+		Help:      "Passes over a cluster that failed, by error class.",
+	}, []string{"cluster", "class"})
+
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_state",
+		Help:      "State of the cluster's circuit breaker: 0 closed, 1 open, 2 half open.",
+	}, []string{"cluster"})
+
+	notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Notifications by result: sent or failed after every attempt.",
+	}, []string{"notifier", "result"})
+
+	notificationAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_attempts_total",
+		Help:      "Delivery attempts made to each notifier, including retries.",
+	}, []string{"notifier"})
+
+	awsRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "aws_request_duration_seconds",
+		Help:      "Latency of AWS API calls including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+
+	awsRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "aws_request_errors_total",
+		Help:      "AWS API calls that failed, by error code.",
+	}, []string{"service", "operation", "code"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		clusterResourceTotal,
+		clusterResourceRemaining,
+		clusterUtilization,
+		clusterInstances,
+		autoScalingGroupSize,
+		alertsCreated,
+		checkDuration,
+		checkEvaluations,
+		checkAlerts,
+		actions,
+		actionDuration,
+		outdatedInstances,
+		clusterFailures,
+		circuitState,
+		notifications,
+		notificationAttempts,
+		awsRequestDuration,
+		awsRequestErrors,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// SetClusterResource records the total and remaining amount of a resource
+// ("cpu" or "memory") and the resulting utilization
+func SetClusterResource(clusterArn string, resource string, total int64, remaining int64, utilization float64) {
+	clusterResourceTotal.WithLabelValues(clusterArn, resource).Set(float64(total))
+	clusterResourceRemaining.WithLabelValues(clusterArn, resource).Set(float64(remaining))
+	clusterUtilization.WithLabelValues(clusterArn, resource).Set(utilization)
+}
+
+// SetClusterInstances records the number of container instances in a cluster
+func SetClusterInstances(clusterArn string, count int) {
+	clusterInstances.WithLabelValues(clusterArn).Set(float64(count))
+}
+
+// SetAutoScalingGroup records the capacity bounds of a cluster's auto scaling group
+func SetAutoScalingGroup(clusterArn string, name string, min int64, max int64, desired int64) {
+	autoScalingGroupSize.WithLabelValues(clusterArn, name, "min").Set(float64(min))
+	autoScalingGroupSize.WithLabelValues(clusterArn, name, "max").Set(float64(max))
+	autoScalingGroupSize.WithLabelValues(clusterArn, name, "desired").Set(float64(desired))
+}
+
+// AlertCreated counts an alert raised by a check
+func AlertCreated(clusterArn string, alertType string, trigger string) {
+	alertsCreated.WithLabelValues(clusterArn, alertType, trigger).Inc()
+}
+
+// CheckEvaluated counts a check run against a cluster, the alerts it returned
+// and how long it took
+func CheckEvaluated(clusterArn string, check string, duration time.Duration, alerts int, err error) {
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	checkEvaluations.WithLabelValues(clusterArn, check, result).Inc()
+	checkAlerts.WithLabelValues(clusterArn, check).Add(float64(alerts))
+	checkDuration.WithLabelValues(check).Observe(duration.Seconds())
+}
+
+// ActionPlanned counts an action recorded but not performed in dry run mode
+func ActionPlanned(clusterArn string, action string) {
+	actions.WithLabelValues(clusterArn, action, "planned").Inc()
+}
+
+// ActionPerformed counts an action sent to AWS and how long it took
+func ActionPerformed(clusterArn string, action string, duration time.Duration, err error) {
+	result := "performed"
+	if err != nil {
+		result = "failed"
+	}
+	actions.WithLabelValues(clusterArn, action, result).Inc()
+	actionDuration.WithLabelValues(action).Observe(duration.Seconds())
+}
+
+// SetOutdatedInstances records how many instances an image refresh has left to replace
+func SetOutdatedInstances(clusterArn string, count int) {
+	outdatedInstances.WithLabelValues(clusterArn).Set(float64(count))
+}
+
+// ClusterFailed counts a failed pass over a cluster
+func ClusterFailed(clusterArn string, class string) {
+	clusterFailures.WithLabelValues(clusterArn, class).Inc()
+}
+
+// SetCircuitState records the state of a cluster's circuit breaker
+func SetCircuitState(clusterArn string, state int) {
+	circuitState.WithLabelValues(clusterArn).Set(float64(state))
+}
+
+// NotificationSent counts a notification delivered, or given up on, after the
+// given number of attempts
+func NotificationSent(notifier string, attempts int, err error) {
+	result := "sent"
+	if err != nil {
+		result = "failed"
+	}
+	notifications.WithLabelValues(notifier, result).Inc()
+	notificationAttempts.WithLabelValues(notifier).Add(float64(attempts))
+}
+
+// InstrumentHandlers adds latency and error metrics to every request made
+// by clients created from a session with these handlers
+func InstrumentHandlers(handlers *request.Handlers) {
+	handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "ecs-manager.metrics",
+		Fn:   observeRequest,
+	})
+}
+
+func observeRequest(r *request.Request) {
+	operation := "?"
+	if r.Operation != nil {
+		operation = r.Operation.Name
+	}
+	service := r.ClientInfo.ServiceName
+
+	awsRequestDuration.WithLabelValues(service, operation).Observe(time.Since(r.Time).Seconds())
+	if r.Error != nil {
+		code := "Unknown"
+		if awsErr, ok := r.Error.(awserr.Error); ok {
+			code = awsErr.Code()
+		}
+		awsRequestErrors.WithLabelValues(service, operation, code).Inc()
+	}
+}
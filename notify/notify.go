@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTemplate renders events for sinks without a template of their own
+const DefaultTemplate = `{{.Type}} alert ({{.Trigger}}) on {{.ClusterName}} went from {{.From}} to {{.To}}{{if .DryRun}} (dry run){{end}}` +
+	`{{range .Actions}}
+- {{.Type}}{{with .ContainerInstanceArn}} {{.}}{{end}}: {{.Reason}}{{with .Error}} failed: {{.}}{{end}}{{end}}`
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = time.Second
+)
+
+// Event is an alert changing status while the manager reconciles its cluster,
+// along with the actions taken for the alert on that pass
+type Event struct {
+	ClusterArn  string
+	ClusterName string
+	AccountId   string
+	Region      string
+	Type        string
+	Trigger     string
+	From        string
+	To          string
+	DryRun      bool
+	Alert       alert.Alert
+	Actions     []*action.Action
+	Time        time.Time
+	// Message is the event rendered with the sink's template
+	Message string
+}
+
+// Notifier delivers an event to an external system
+type Notifier interface {
+	// Name identifies the notifier in logs and metrics
+	Name() string
+	Notify(event *Event) error
+}
+
+// permanentError is a delivery failure retrying will not fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks err as a failure retrying will not fix, such as a request
+// the receiver rejected
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
+
+// Sink sends the events matching its patterns to a notifier, retrying failed
+// deliveries with exponential backoff
+type Sink struct {
+	notifier    Notifier
+	patterns    []string
+	template    *template.Template
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewSink returns a sink for the events matching patterns
+func NewSink(notifier Notifier, patterns []string, templateText string, maxAttempts int, backoff time.Duration) (*Sink, error) {
+	for _, pattern := range patterns {
+		if err := ValidPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	if templateText == "" {
+		templateText = DefaultTemplate
+	}
+	parsed, err := ParseTemplate(templateText)
+	if err != nil {
+		return nil, err
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	return &Sink{
+		notifier:    notifier,
+		patterns:    patterns,
+		template:    parsed,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}, nil
+}
+
+// ParseTemplate parses a message template rendered with an Event
+func ParseTemplate(text string) (*template.Template, error) {
+	parsed, err := template.New("notification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return parsed, parsed.Execute(&bytes.Buffer{}, &Event{})
+}
+
+// ValidPattern checks an event pattern written as Type, Type:Status or
+// Type:From->To, where each part is a name or * for any
+func ValidPattern(pattern string) error {
+	typeName, from, to := splitPattern(pattern)
+	if typeName != "*" {
+		if _, err := alert.ParseType(typeName); err != nil {
+			return fmt.Errorf("%q: %s", pattern, err)
+		}
+	}
+	for _, status := range []string{from, to} {
+		if status == "*" {
+			continue
+		}
+		if _, err := alert.ParseStatus(status); err != nil {
+			return fmt.Errorf("%q: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// splitPattern returns the type, from and to status of a pattern, * for the
+// parts it leaves out
+func splitPattern(pattern string) (string, string, string) {
+	parts := strings.SplitN(pattern, ":", 2)
+	if len(parts) == 1 {
+		return parts[0], "*", "*"
+	}
+	statuses := strings.SplitN(parts[1], "->", 2)
+	if len(statuses) == 1 {
+		return parts[0], "*", statuses[0]
+	}
+	return parts[0], statuses[0], statuses[1]
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the sink sends the event: every event when the
+// sink has no patterns, otherwise the events matching one of them
+func (s *Sink) Matches(event *Event) bool {
+	if len(s.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range s.patterns {
+		typeName, from, to := splitPattern(pattern)
+		if oneOf(typeName, "*", event.Type) && oneOf(from, "*", event.From) && oneOf(to, "*", event.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// pending counts the deliveries still running in the background
+var pending sync.WaitGroup
+
+// Send delivers the event to every matching sink in the background
+func Send(sinks []*Sink, event Event) {
+	for _, sink := range sinks {
+		if !sink.Matches(&event) {
+			continue
+		}
+		var message bytes.Buffer
+		if err := sink.template.Execute(&message, &event); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Notifier": sink.notifier.Name(),
+				"Alert":    event.Alert,
+			}).Error(err)
+			continue
+		}
+		delivered := event
+		delivered.Message = message.String()
+
+		pending.Add(1)
+		go func(sink *Sink) {
+			defer pending.Done()
+			sink.deliver(&delivered)
+		}(sink)
+	}
+}
+
+// Wait reports whether every delivery finished before timeout
+func Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// deliver sends the event, retrying until it is delivered, fails permanently
+// or runs out of attempts
+func (s *Sink) deliver(event *Event) error {
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		err := s.notifier.Notify(event)
+		fields := logrus.Fields{
+			"Notifier":   s.notifier.Name(),
+			"ClusterArn": event.ClusterArn,
+			"Type":       event.Type,
+			"Status":     event.To,
+			"Attempt":    attempt,
+		}
+		if err == nil {
+			metrics.NotificationSent(s.notifier.Name(), attempt, nil)
+			logrus.WithFields(fields).Info("Sent Notification")
+			return nil
+		}
+		if IsPermanent(err) || attempt >= s.maxAttempts {
+			metrics.NotificationSent(s.notifier.Name(), attempt, err)
+			logrus.WithFields(fields).Error(err)
+			return err
+		}
+		logrus.WithFields(fields).Warn(err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
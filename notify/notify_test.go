@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"testing"
+)
+
+// nopNotifier drops every event
+type nopNotifier struct{}
+
+func (nopNotifier) Name() string {
+	return "nop"
+}
+
+func (nopNotifier) Notify(event *Event) error {
+	return nil
+}
+
+func TestValidPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"*", true},
+		{"ScaleUp", true},
+		{"ScaleDown:InProgress", true},
+		{"*:Pending->Completed", true},
+		{"Retire:*->*", true},
+		{"Escalation:Pending", true},
+		{"*:Failed", true},
+		{"ScaleDown:Pending->Cancelled", true},
+		{"*:*", true},
+		{"", false},
+		{"scaleup", false},
+		{"Resize", false},
+		{"ScaleUp:Done", false},
+		{"ScaleUp:Pending->", false},
+		{"ScaleUp:->Completed", false},
+		{"*:Pending->InProgress->Completed", false},
+	}
+	for _, test := range tests {
+		err := ValidPattern(test.pattern)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("ValidPattern(%q) = %v, want valid %t", test.pattern, err, test.valid)
+		}
+	}
+}
+
+func TestSinkMatches(t *testing.T) {
+	scaleDownStarted := &Event{Type: "ScaleDown", From: "Pending", To: "InProgress"}
+	scaleUpCompleted := &Event{Type: "ScaleUp", From: "InProgress", To: "Completed"}
+	retireRaised := &Event{Type: "Retire", From: "Created", To: "Pending"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		event    *Event
+		want     bool
+	}{
+		{"no patterns", nil, scaleDownStarted, true},
+		{"any", []string{"*"}, retireRaised, true},
+		{"type", []string{"ScaleDown"}, scaleDownStarted, true},
+		{"other type", []string{"ScaleDown"}, scaleUpCompleted, false},
+		{"status", []string{"*:InProgress"}, scaleDownStarted, true},
+		{"status is the new one", []string{"*:InProgress"}, scaleUpCompleted, false},
+		{"transition", []string{"*:InProgress->Completed"}, scaleUpCompleted, true},
+		{"other transition", []string{"*:Pending->Completed"}, scaleUpCompleted, false},
+		{"any from", []string{"Retire:*->Pending"}, retireRaised, true},
+		{"one of several", []string{"ScaleUp", "Retire:Pending"}, retireRaised, true},
+		{"none of several", []string{"ScaleUp", "Retire:Completed"}, scaleDownStarted, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink, err := NewSink(nopNotifier{}, test.patterns, "", 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched := sink.Matches(test.event); matched != test.want {
+				t.Fatalf("Matches(%+v) with %q = %t, want %t", test.event, test.patterns, matched, test.want)
+			}
+		})
+	}
+}
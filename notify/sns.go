@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// SNSAPI is the subset of the SNS API used by SNSNotifier
+type SNSAPI interface {
+	Publish(input *sns.PublishInput) (*sns.PublishOutput, error)
+}
+
+// SNSNotifier publishes the rendered message of every event to an SNS topic
+type SNSNotifier struct {
+	service  SNSAPI
+	topicArn string
+}
+
+// NewSNSNotifier returns a notifier publishing to the given topic
+func NewSNSNotifier(service SNSAPI, topicArn string) *SNSNotifier {
+	return &SNSNotifier{
+		service:  service,
+		topicArn: topicArn,
+	}
+}
+
+func (n *SNSNotifier) Name() string {
+	return "sns"
+}
+
+func (n *SNSNotifier) Notify(event *Event) error {
+	subject := fmt.Sprintf("ecs-manager: %s %s on %s", event.Type, event.To, event.ClusterName)
+	if len(subject) > 100 {
+		subject = subject[:100]
+	}
+	_, err := n.service.Publish(&sns.PublishInput{
+		TopicArn: aws.String(n.topicArn),
+		Subject:  aws.String(subject),
+		Message:  aws.String(event.Message),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"ClusterArn": {DataType: aws.String("String"), StringValue: aws.String(event.ClusterArn)},
+			"Type":       {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"Status":     {DataType: aws.String("String"), StringValue: aws.String(event.To)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case sns.ErrCodeAuthorizationErrorException, sns.ErrCodeNotFoundException, sns.ErrCodeInvalidParameterException:
+			return Permanent(err)
+		}
+	}
+	return err
+}
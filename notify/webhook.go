@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpClient sends Slack and webhook notifications
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookNotifier posts every event as a JSON document, including its
+// rendered Message, to an HTTP endpoint
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+// NewWebhookNotifier returns a notifier posting to url with the extra headers,
+// e.g. an Authorization header
+func NewWebhookNotifier(url string, headers map[string]string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:     url,
+		headers: headers,
+	}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(event *Event) error {
+	return postJSON(n.url, n.headers, event)
+}
+
+// SlackNotifier posts the rendered message of every event to a Slack incoming
+// webhook
+type SlackNotifier struct {
+	url string
+}
+
+// NewSlackNotifier returns a notifier posting to the Slack incoming webhook url
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{url: url}
+}
+
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Notify(event *Event) error {
+	return postJSON(n.url, nil, map[string]string{"text": event.Message})
+}
+
+// postJSON posts value to url, client errors but 429 are permanent
+func postJSON(url string, headers map[string]string, value interface{}) error {
+	document, err := json.Marshal(value)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(document))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("POST %s: %s %s", req.URL.Host, res.Status, bytes.TrimSpace(body))
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
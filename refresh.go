@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sd-charris/ecs-manager/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// imageRefreshCheck retires the next batch of instances on an outdated AMI
+type imageRefreshCheck struct{}
+
+func (imageRefreshCheck) Name() string {
+	return "AMIRefresh"
+}
+
+func (imageRefreshCheck) Enabled(settings *config.Config) bool {
+	return settings.CheckEnabled("AMIRefresh") && settings.AMIRefresh != "" && settings.AMIRefresh != "off"
+}
+
+func (imageRefreshCheck) Evaluate(cluster *ecs.ClusterDetails, settings *config.Config) ([]*alert.Alert, error) {
+	if cluster.AutoScalingGroup == nil {
+		return nil, nil
+	}
+	targetImageId, err := targetImageId(cluster, settings)
+	if err != nil || targetImageId == "" {
+		return nil, err
+	}
+	cluster.TargetImageId = targetImageId
+
+	err = cluster.DescribeImages()
+	if err != nil {
+		return nil, err
+	}
+	outdated := outdatedInstances(cluster)
+	metrics.SetOutdatedInstances(*cluster.ClusterArn, len(outdated))
+	logrus.WithFields(logrus.Fields{
+		"ClusterArn":    *cluster.ClusterArn,
+		"TargetImageId": targetImageId,
+		"Outdated":      len(outdated),
+		"Instances":     len(cluster.ContainerInstances),
+	}).Info("Image Refresh Progress")
+	if len(outdated) == 0 {
+		return nil, nil
+	}
+
+	if int64(len(outdated)) > settings.AMIRefreshBatchSize {
+		outdated = outdated[:settings.AMIRefreshBatchSize]
+	}
+	refreshAlert := alert.NewAlert(alert.Retire, alert.Image, *cluster.ClusterArn, *outdated[0].ContainerInstanceArn)
+	for _, instance := range outdated {
+		refreshAlert.ContainerInstanceArns = append(refreshAlert.ContainerInstanceArns, *instance.ContainerInstanceArn)
+	}
+	logrus.WithFields(logrus.Fields{
+		"Alert": refreshAlert,
+		"Batch": refreshAlert.ContainerInstanceArns,
+	}).Info("Creating Alert")
+	return []*alert.Alert{refreshAlert}, nil
+}
+
+// targetImageId is empty while the group does not launch the latest AMI yet
+func targetImageId(cluster *ecs.ClusterDetails, settings *config.Config) (string, error) {
+	launchImageId, err := cluster.LaunchImageId()
+	if err != nil {
+		return "", err
+	}
+	if settings.AMIRefresh != "ssm" {
+		return launchImageId, nil
+	}
+
+	latestImageId, err := cluster.ParameterImageId(settings.AMIRefreshParameter)
+	if err != nil {
+		return "", err
+	}
+	if latestImageId != launchImageId {
+		logrus.WithFields(logrus.Fields{
+			"ClusterArn":    *cluster.ClusterArn,
+			"LatestImageId": latestImageId,
+			"LaunchImageId": launchImageId,
+		}).Warn("Auto Scaling group does not launch the latest AMI, skipping image refresh")
+		return "", nil
+	}
+	return latestImageId, nil
+}
+
+// outdatedInstances returns the active instances not running the cluster's
+// target AMI, oldest first
+func outdatedInstances(cluster *ecs.ClusterDetails) []*ecs.ContainerInstance {
+	outdated := make([]*ecs.ContainerInstance, 0)
+	for _, instance := range cluster.ContainerInstances {
+		if instance.ImageId == nil || *instance.ImageId == cluster.TargetImageId {
+			continue
+		}
+		if instance.Status == nil || *instance.Status != "ACTIVE" {
+			continue
+		}
+		outdated = append(outdated, instance)
+	}
+	sort.SliceStable(outdated, func(i, j int) bool {
+		return outdated[i].RegisteredDate.Before(*outdated[j].RegisteredDate)
+	})
+	return outdated
+}
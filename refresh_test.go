@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/ecs"
+)
+
+func TestImageRefreshReplacesOutdatedInstances(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 4, 256, 512)
+	outdated := make([]string, 0)
+	for _, instance := range group.Group.Instances {
+		outdated = append(outdated, *instance.InstanceId)
+	}
+	group.ImageId = "ami-00000002"
+	newTestManager(t, fake, `{
+		"AlertIntervalCount": 1,
+		"ResourceRemoveThresholdPercent": 0.1,
+		"AMIRefresh": "launch-template",
+		"AMIRefreshBatchSize": 1,
+		"AMIRefreshSurgeCount": 1
+	}`)
+
+	for pass := 0; pass < 30 && len(fake.TerminatedInstanceIds) < len(outdated); pass++ {
+		runPasses(t, 1)
+		if desired := *group.Group.DesiredCapacity; desired > 3 {
+			t.Fatalf("desired capacity = %d on pass %d, want at most one surge instance", desired, pass)
+		}
+	}
+	runPasses(t, 3)
+
+	terminated := append([]string{}, fake.TerminatedInstanceIds...)
+	sort.Strings(terminated)
+	if len(terminated) != 2 || terminated[0] != outdated[0] || terminated[1] != outdated[1] {
+		t.Fatalf("terminated %v, want the outdated instances %v", terminated, outdated)
+	}
+	if desired := *group.Group.DesiredCapacity; desired != 2 {
+		t.Fatalf("desired capacity = %d, want 2 restored after the refresh", desired)
+	}
+	for _, refreshAlert := range ecsClusters[clusterArn].Alerts {
+		if refreshAlert.Trigger == alert.Image && refreshAlert.Status != alert.Completed {
+			t.Fatalf("alert %v still open", refreshAlert)
+		}
+	}
+}
+
+func TestImageRefreshWaitsForLatestImageInSSMMode(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 4, 256, 512)
+	fake.Parameters = map[string]string{"/ecs/image_id": "ami-00000002"}
+	newTestManager(t, fake, `{
+		"AlertIntervalCount": 1,
+		"ResourceRemoveThresholdPercent": 0.1,
+		"AMIRefresh": "ssm",
+		"AMIRefreshParameter": "/ecs/image_id"
+	}`)
+
+	//the group still launches the old image, replacing instances would not help
+	runPasses(t, 5)
+	if len(fake.TerminatedInstanceIds) != 0 || *group.Group.DesiredCapacity != 2 {
+		t.Fatalf("terminated %v at desired %d, want the cluster left alone", fake.TerminatedInstanceIds, *group.Group.DesiredCapacity)
+	}
+
+	group.ImageId = "ami-00000002"
+	for pass := 0; pass < 30 && len(fake.TerminatedInstanceIds) < 2; pass++ {
+		runPasses(t, 1)
+	}
+	if len(fake.TerminatedInstanceIds) != 2 {
+		t.Fatalf("terminated %v, want both outdated instances", fake.TerminatedInstanceIds)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileRetire moves a Retire alert through its Steps
+func (ecsCluster *ECSCluster) reconcileRetire(retireAlert *alert.Alert, surge int64) {
+	cluster := ecsCluster.ClusterDetails
+	group := cluster.AutoScalingGroup
+	if group == nil {
+		return
+	}
+
+	//alerts saved before retirements had steps went InProgress once surged
+	if retireAlert.Status == alert.InProgress && retireAlert.Step == alert.Surge {
+		retireAlert.Step = alert.AwaitReplacement
+	}
+	if len(retireAlert.ContainerInstanceArns) == 0 && retireAlert.Step < alert.Remove && retireAlert.ContainerInstanceArn != "" {
+		retireAlert.ContainerInstanceArns = []string{retireAlert.ContainerInstanceArn}
+	}
+
+	switch retireAlert.Step {
+	case alert.Surge:
+		desired := *group.DesiredInstanceCount
+		retireAlert.Capacity = &alert.Capacity{Desired: &desired}
+
+		if count := int64(len(retireAlert.ContainerInstanceArns)); surge > count {
+			surge = count
+		}
+		//at its maximum the group has no room to surge, the retiring
+		//instances' tasks must fit on the others
+		if room := *group.MaxInstanceCount - desired; surge > room {
+			surge = room
+		}
+		if surge < 1 {
+			transition(retireAlert, alert.InProgress, "no room to surge")
+			retireAlert.Step = alert.AwaitReplacement
+			return
+		}
+		performed, _ := ecsCluster.perform(action.IncreaseCapacity, retireAlert, "", "launch replacements for retiring instances", func() error {
+			return cluster.IncreaseClusterCapacity(surge)
+		})
+		if performed {
+			transition(retireAlert, alert.InProgress, "replacements launched")
+			retireAlert.Step = alert.AwaitReplacement
+		}
+
+	case alert.AwaitReplacement:
+		if int64(len(cluster.ContainerInstances)) < *group.DesiredInstanceCount {
+			logrus.WithFields(logrus.Fields{
+				"Alert": retireAlert,
+			}).Info("Waiting for replacement instances")
+			return
+		}
+		retireAlert.Step = alert.Drain
+		ecsCluster.reconcileRetire(retireAlert, surge)
+
+	case alert.Drain:
+		active := make([]*string, 0)
+		for _, containerInstanceArn := range retireAlert.ContainerInstanceArns {
+			instance := cluster.GetContainerInstance(&containerInstanceArn)
+			if instance != nil && instance.Status != nil && *instance.Status == "ACTIVE" {
+				active = append(active, instance.ContainerInstanceArn)
+			}
+		}
+		if len(active) > 0 {
+			if simulation := cluster.SimulateDrain(active...); !simulation.Fits() {
+				logrus.WithFields(logrus.Fields{
+					"Alert":  retireAlert,
+					"Reason": simulation.Reason,
+				}).Info("Waiting for room to drain retiring instances")
+				return
+			}
+		}
+		for _, containerInstanceArn := range active {
+			containerInstanceArn := containerInstanceArn
+			_, err := ecsCluster.perform(action.DrainInstance, retireAlert, *containerInstanceArn, "instance marked for retirement", func() error {
+				_, err := cluster.DrainClusterInstance(containerInstanceArn)
+				return err
+			})
+			if err != nil {
+				return
+			}
+		}
+		retireAlert.DrainStartDate = time.Now()
+		retireAlert.Step = alert.Remove
+
+	case alert.Remove:
+		ecsCluster.removeDrainedInstances(retireAlert)
+		if len(retireAlert.ContainerInstanceArns) > 0 {
+			return
+		}
+		//restore on the next pass, once the group shows the terminations
+		retireAlert.Step = alert.Restore
+
+	case alert.Restore:
+		if retireAlert.Capacity != nil && retireAlert.Capacity.Desired != nil && *group.DesiredInstanceCount < *retireAlert.Capacity.Desired {
+			desired := *retireAlert.Capacity.Desired
+			if desired > *group.MaxInstanceCount {
+				desired = *group.MaxInstanceCount
+			}
+			_, err := ecsCluster.perform(action.SetCapacity, retireAlert, "", "restore capacity after retiring instances", func() error {
+				return cluster.SetClusterCapacity(nil, nil, &desired)
+			})
+			if err != nil {
+				return
+			}
+		}
+		transition(retireAlert, alert.Completed, "instances retired")
+	}
+}
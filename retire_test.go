@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/ecs"
+)
+
+// retireOldestInstance ages the first instance of a two instance cluster past
+// InstanceMaxAgeDays, runs passes until it is terminated and returns the
+// steps the Retire alert went through and the largest desired capacity seen
+func retireOldestInstance(t *testing.T, fake *ecs.FakeAWS, group *ecs.FakeAutoScalingGroup) ([]alert.Step, int64) {
+	t.Helper()
+	clusterArn := group.ClusterArn
+	fake.Clusters[0].ContainerInstances[0].RegisteredAt = aws.Time(time.Now().AddDate(0, 0, -30))
+	newTestManager(t, fake, `{
+		"AlertIntervalCount": 1,
+		"ResourceRemoveThresholdPercent": 0.1
+	}`)
+
+	steps := make([]alert.Step, 0)
+	largest := *group.Group.DesiredCapacity
+	for pass := 0; pass < 30 && len(fake.TerminatedInstanceIds) == 0; pass++ {
+		runPasses(t, 1)
+		if desired := *group.Group.DesiredCapacity; desired > largest {
+			largest = desired
+		}
+		for _, retireAlert := range ecsClusters[clusterArn].Alerts {
+			if retireAlert.Type == alert.Retire && retireAlert.Status == alert.InProgress {
+				if len(steps) == 0 || steps[len(steps)-1] != retireAlert.Step {
+					steps = append(steps, retireAlert.Step)
+				}
+			}
+		}
+	}
+	runPasses(t, 3)
+	return steps, largest
+}
+
+func TestRetireSurgesDrainsAndRestores(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 4, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 4, 256, 512)
+	retiring := *group.Group.Instances[0].InstanceId
+
+	steps, largest := retireOldestInstance(t, fake, group)
+
+	if len(fake.TerminatedInstanceIds) != 1 || fake.TerminatedInstanceIds[0] != retiring {
+		t.Fatalf("terminated %v, want the retired instance %s", fake.TerminatedInstanceIds, retiring)
+	}
+	if largest != 3 {
+		t.Fatalf("largest desired capacity = %d, want one surge instance", largest)
+	}
+	if fmt.Sprint(steps) != "[AwaitReplacement Remove Restore]" {
+		t.Fatalf("steps = %v, want AwaitReplacement, Remove and Restore", steps)
+	}
+	if desired := *group.Group.DesiredCapacity; desired != 2 {
+		t.Fatalf("desired capacity = %d, want 2 restored after the retirement", desired)
+	}
+	for _, retireAlert := range ecsClusters[clusterArn].Alerts {
+		if retireAlert.Type == alert.Retire && retireAlert.Status != alert.Completed {
+			t.Fatalf("alert %v still open", retireAlert)
+		}
+	}
+}
+
+func TestRetireWithoutRoomToSurge(t *testing.T) {
+	fake := ecs.NewFakeAWS()
+	clusterArn := *fake.AddCluster("web").Cluster.ClusterArn
+	group := fake.AddAutoScalingGroup("web-asg", clusterArn, 1, 2, 2, 1024, 2048)
+	fake.AddService(clusterArn, "api", 2, 256, 512)
+	retiring := *group.Group.Instances[0].InstanceId
+
+	_, largest := retireOldestInstance(t, fake, group)
+
+	if largest != 2 {
+		t.Fatalf("largest desired capacity = %d, want the group kept at its max of 2", largest)
+	}
+	if len(fake.TerminatedInstanceIds) != 1 || fake.TerminatedInstanceIds[0] != retiring {
+		t.Fatalf("terminated %v, want the retired instance %s drained onto the other", fake.TerminatedInstanceIds, retiring)
+	}
+	if desired := *group.Group.DesiredCapacity; desired != 2 {
+		t.Fatalf("desired capacity = %d, want 2 restored after the retirement", desired)
+	}
+}
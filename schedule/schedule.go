@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is "[days] HH:MM [zone] set min|max|desired N" or "... scale to N"
+type Schedule struct {
+	Text     string
+	Days     [7]bool
+	Hour     int
+	Minute   int
+	Location *time.Location
+	Min      *int64
+	Max      *int64
+	Desired  *int64
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse reads a schedule written as described on Schedule
+func Parse(text string) (*Schedule, error) {
+	fields := strings.Fields(text)
+	schedule := &Schedule{Text: text, Location: time.UTC}
+
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", text, err)
+		}
+		schedule.Days = days
+		fields = fields[1:]
+	} else {
+		schedule.Days = [7]bool{true, true, true, true, true, true, true}
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%q: expected a time of day as HH:MM", text)
+	}
+	clock, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("%q: expected a time of day as HH:MM, got %q", text, fields[0])
+	}
+	schedule.Hour = clock.Hour()
+	schedule.Minute = clock.Minute()
+	fields = fields[1:]
+
+	if len(fields) > 0 && !oneOf(strings.ToLower(fields[0]), "set", "scale") {
+		location, err := time.LoadLocation(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q: unknown time zone %q", text, fields[0])
+		}
+		schedule.Location = location
+		fields = fields[1:]
+	}
+
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%q: expected set min|max|desired N or scale to N", text)
+	}
+	count, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("%q: expected an instance count, got %q", text, fields[2])
+	}
+	switch strings.ToLower(fields[0] + " " + fields[1]) {
+	case "set min":
+		schedule.Min = &count
+	case "set max":
+		schedule.Max = &count
+	case "set desired", "scale to":
+		schedule.Desired = &count
+	default:
+		return nil, fmt.Errorf("%q: expected set min|max|desired N or scale to N", text)
+	}
+	return schedule, nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDays reads daily, weekdays, weekends or a list of day names and ranges
+func parseDays(text string) ([7]bool, error) {
+	var days [7]bool
+
+	switch strings.ToLower(text) {
+	case "daily", "everyday":
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	case "weekdays":
+		return [7]bool{false, true, true, true, true, true, false}, nil
+	case "weekends":
+		return [7]bool{true, false, false, false, false, false, true}, nil
+	}
+
+	for _, item := range strings.Split(strings.ToLower(text), ",") {
+		bounds := strings.SplitN(item, "-", 2)
+		first, ok := parseDay(bounds[0])
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = parseDay(bounds[1]); !ok {
+				return days, fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseDay accepts a day name or its first three letters
+func parseDay(text string) (time.Weekday, bool) {
+	if len(text) < 3 {
+		return 0, false
+	}
+	day, ok := dayNames[text[:3]]
+	if ok && len(text) > 3 && !strings.HasPrefix(strings.ToLower(day.String()), text) {
+		return 0, false
+	}
+	return day, ok
+}
+
+// Occurred reports whether the schedule ran after since and at or before until
+func (s *Schedule) Occurred(since time.Time, until time.Time) bool {
+	start := since.In(s.Location)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, s.Location)
+	for !day.After(until) {
+		occurrence := time.Date(day.Year(), day.Month(), day.Day(), s.Hour, s.Minute, 0, 0, s.Location)
+		if s.Days[occurrence.Weekday()] && occurrence.After(since) && !occurrence.After(until) {
+			return true
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return false
+}
+
+func (s *Schedule) String() string {
+	return s.Text
+}
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	count := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		text     string
+		want     Schedule
+		problems bool
+	}{
+		{text: "22:00 scale to 2", want: Schedule{Days: [7]bool{true, true, true, true, true, true, true}, Hour: 22, Location: time.UTC, Desired: count(2)}},
+		{text: "weekdays 07:30 America/Los_Angeles set min 4", want: Schedule{Days: [7]bool{false, true, true, true, true, true, false}, Hour: 7, Minute: 30, Location: losAngeles, Min: count(4)}},
+		{text: "sat,sun 00:05 Set Max 10", want: Schedule{Days: [7]bool{true, false, false, false, false, false, true}, Minute: 5, Location: time.UTC, Max: count(10)}},
+		{text: "mon 12:00 set desired 0", want: Schedule{Days: [7]bool{false, true}, Hour: 12, Location: time.UTC, Desired: count(0)}},
+		{text: "scale to 2", problems: true},
+		{text: "25:00 scale to 2", problems: true},
+		{text: "22:00 Mars/Olympus scale to 2", problems: true},
+		{text: "22:00 scale to -1", problems: true},
+		{text: "22:00 scale down 2", problems: true},
+		{text: "22:00 set min", problems: true},
+		{text: "someday 22:00 scale to 2", problems: true},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.text)
+		if test.problems {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", test.text, schedule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.text, err)
+			continue
+		}
+		if schedule.Days != test.want.Days || schedule.Hour != test.want.Hour || schedule.Minute != test.want.Minute || schedule.Location.String() != test.want.Location.String() {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.text, schedule, test.want)
+		}
+		for _, field := range []struct {
+			name      string
+			got, want *int64
+		}{{"Min", schedule.Min, test.want.Min}, {"Max", schedule.Max, test.want.Max}, {"Desired", schedule.Desired, test.want.Desired}} {
+			if (field.got == nil) != (field.want == nil) || (field.got != nil && *field.got != *field.want) {
+				t.Errorf("Parse(%q) %s = %v, want %v", test.text, field.name, field.got, field.want)
+			}
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		text string
+		want []time.Weekday
+	}{
+		{"daily", []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}},
+		{"weekends", []time.Weekday{time.Sunday, time.Saturday}},
+		{"mon-fri", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+		//ranges wrap around the end of the week
+		{"fri-mon", []time.Weekday{time.Sunday, time.Monday, time.Friday, time.Saturday}},
+		{"sat-sat", []time.Weekday{time.Saturday}},
+		{"MON,wed,Fri", []time.Weekday{time.Monday, time.Wednesday, time.Friday}},
+		//full names and any prefix of three letters or more
+		{"monday-wednesday", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday}},
+		{"tues,thurs", []time.Weekday{time.Tuesday, time.Thursday}},
+		{"sun,tue-thu", []time.Weekday{time.Sunday, time.Tuesday, time.Wednesday, time.Thursday}},
+		{"mo", nil},
+		{"mondays", nil},
+		{"thx", nil},
+		{"mon-", nil},
+		{"mon,,fri", nil},
+	}
+	for _, test := range tests {
+		days, err := parseDays(test.text)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("parseDays(%q) = %v, want an error", test.text, days)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDays(%q): %v", test.text, err)
+			continue
+		}
+		var want [7]bool
+		for _, day := range test.want {
+			want[day] = true
+		}
+		if days != want {
+			t.Errorf("parseDays(%q) = %v, want %v", test.text, days, want)
+		}
+	}
+}
+
+func TestOccurred(t *testing.T) {
+	at := func(text string) time.Time {
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	if _, err := time.LoadLocation("America/Los_Angeles"); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		schedule string
+		since    string
+		until    string
+		want     bool
+	}{
+		{"22:00 scale to 2", "2024-01-10T21:59:00Z", "2024-01-10T22:00:00Z", true},
+		{"22:00 scale to 2", "2024-01-10T22:00:00Z", "2024-01-10T22:05:00Z", false},
+		{"22:00 scale to 2", "2024-01-10T21:00:00Z", "2024-01-10T21:59:59Z", false},
+		//past midnight in UTC, still the previous evening in Los Angeles
+		{"wed 18:00 America/Los_Angeles scale to 2", "2024-01-11T01:59:00Z", "2024-01-11T02:00:00Z", true},
+		{"thu 18:00 America/Los_Angeles scale to 2", "2024-01-11T01:59:00Z", "2024-01-11T02:00:00Z", false},
+		//09:00 is 17:00 UTC before the spring change and 16:00 UTC after it
+		{"09:00 America/Los_Angeles scale to 2", "2024-03-09T16:59:00Z", "2024-03-09T17:00:00Z", true},
+		{"09:00 America/Los_Angeles scale to 2", "2024-03-11T15:59:00Z", "2024-03-11T16:00:00Z", true},
+		{"09:00 America/Los_Angeles scale to 2", "2024-03-11T16:01:00Z", "2024-03-11T17:00:00Z", false},
+		//and back to 17:00 UTC after the autumn change
+		{"09:00 America/Los_Angeles scale to 2", "2024-11-04T16:00:00Z", "2024-11-04T16:59:00Z", false},
+		{"09:00 America/Los_Angeles scale to 2", "2024-11-04T16:59:00Z", "2024-11-04T17:00:00Z", true},
+		//a pass that resumes days later catches up on what it missed
+		{"sat 10:00 scale to 2", "2024-01-12T12:00:00Z", "2024-01-15T08:00:00Z", true},
+		{"wed 10:00 scale to 2", "2024-01-12T12:00:00Z", "2024-01-15T08:00:00Z", false},
+		{"fri-mon 23:30 Asia/Tokyo scale to 2", "2024-01-08T12:00:00Z", "2024-01-11T12:00:00Z", true},
+		{"tue-thu 23:30 Asia/Tokyo scale to 2", "2024-01-07T15:00:00Z", "2024-01-08T14:29:00Z", false},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := schedule.Occurred(at(test.since), at(test.until)); got != test.want {
+			t.Errorf("%q occurred between %s and %s = %t, want %t", test.schedule, test.since, test.until, got, test.want)
+		}
+	}
+}
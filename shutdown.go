@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/sd-charris/ecs-manager/config"
+	"github.com/sd-charris/ecs-manager/notify"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes of the manager
+const (
+	// exitShutdown follows a SIGTERM or SIGINT once the last pass finished
+	exitShutdown = 0
+	// exitFailure follows a startup or check pass error
+	exitFailure = 1
+	// exitPanic follows a recovered panic, matching the Go runtime's own code
+	exitPanic = 2
+	// exitInterrupted follows a second signal received while shutting down
+	exitInterrupted = 130
+)
+
+// errShuttingDown is returned by perform for actions not started because the
+// manager is stopping
+var errShuttingDown = errors.New("manager is shutting down, action not started")
+
+// stopping is set once a shutdown signal arrives
+var stopping int32
+
+// shuttingDown reports whether the manager received a shutdown signal
+func shuttingDown() bool {
+	return atomic.LoadInt32(&stopping) == 1
+}
+
+// handleSignals closes the channel on the first signal, a second exits
+func handleSignals() <-chan struct{} {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	stop := make(chan struct{})
+	go func() {
+		received := <-signals
+		logrus.WithFields(logrus.Fields{
+			"Signal": received,
+		}).Warn("Shutting Down, finishing the current check")
+		atomic.StoreInt32(&stopping, 1)
+		close(stop)
+
+		received = <-signals
+		logrus.WithFields(logrus.Fields{
+			"Signal": received,
+		}).Error("Second signal received, exiting without waiting")
+		logrus.Exit(exitInterrupted)
+	}()
+	return stop
+}
+
+// shutdown saves state, hands over leadership and waits for notifications
+func shutdown(code int) {
+	timeout := time.Duration(config.Get().ShutdownTimeoutSeconds) * time.Second
+	logrus.WithFields(logrus.Fields{
+		"ExitCode": code,
+		"Timeout":  timeout,
+	}).Info("Stopping ECS Manager")
+
+	if elector == nil || elector.IsLeader() {
+		for clusterArn := range ecsClusters {
+			saveState(clusterArn)
+		}
+	}
+	if elector != nil {
+		err := elector.Resign()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}
+
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := httpServer.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}
+
+	if !notify.Wait(timeout) {
+		logrus.Warn("Notifications still being sent at shutdown were dropped")
+	}
+	logrus.Exit(code)
+}
+
+// flushLogs flushes a log hook that buffers entries
+func flushLogs(hook logrus.Hook) {
+	if flusher, ok := hook.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package state
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/go-errors/errors"
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB API used by DynamoDBStore
+type DynamoDBAPI interface {
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+}
+
+// DynamoDBStore keeps each cluster's alerts as JSON in a table keyed by ClusterArn
+type DynamoDBStore struct {
+	service   DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBStore returns a DynamoDBStore using the given table
+func NewDynamoDBStore(service DynamoDBAPI, tableName string) *DynamoDBStore {
+	return &DynamoDBStore{
+		service:   service,
+		tableName: tableName,
+	}
+}
+
+func (s *DynamoDBStore) Load() (map[string][]*alert.Alert, error) {
+	clusters := make(map[string][]*alert.Alert)
+	req := dynamodb.ScanInput{TableName: aws.String(s.tableName), ConsistentRead: aws.Bool(true)}
+	for {
+		res, err := s.service.Scan(&req)
+		if err != nil {
+			return nil, errors.Wrap(err, 1)
+		}
+
+		for _, item := range res.Items {
+			clusterArn, ok := item["ClusterArn"]
+			if !ok || clusterArn.S == nil {
+				continue
+			}
+			alerts := make([]*alert.Alert, 0)
+			if document, ok := item["Alerts"]; ok && document.S != nil {
+				err = json.Unmarshal([]byte(*document.S), &alerts)
+				if err != nil {
+					return nil, errors.Wrap(err, 1)
+				}
+			}
+			clusters[*clusterArn.S] = alerts
+		}
+
+		if len(res.LastEvaluatedKey) == 0 {
+			break
+		}
+		req.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+	return clusters, nil
+}
+
+func (s *DynamoDBStore) Save(clusterArn string, alerts []*alert.Alert) error {
+	document, err := json.Marshal(alerts)
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+
+	_, err = s.service.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"ClusterArn": {S: aws.String(clusterArn)},
+			"Alerts":     {S: aws.String(string(document))},
+			"UpdatedAt":  {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+// fakeDynamoDB keeps the items of one table by ClusterArn, scanning them one
+// per page so Load has to follow LastEvaluatedKey
+type fakeDynamoDB struct {
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.items[*input.Item["ClusterArn"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	keys := make([]string, 0, len(f.items))
+	for key := range f.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if input.ExclusiveStartKey != nil && key <= *input.ExclusiveStartKey["ClusterArn"].S {
+			continue
+		}
+		output := &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{f.items[key]}}
+		if key != keys[len(keys)-1] {
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"ClusterArn": {S: aws.String(key)}}
+		}
+		return output, nil
+	}
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func TestDynamoDBStoreRoundTrip(t *testing.T) {
+	service := &fakeDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+	store := NewDynamoDBStore(service, "ecs-manager-state")
+	clusterArns := []string{
+		"arn:aws:ecs:us-west-2:123456789012:cluster/api",
+		"arn:aws:ecs:us-west-2:123456789012:cluster/web",
+		"arn:aws:ecs:us-west-2:123456789012:cluster/worker",
+	}
+	for _, clusterArn := range clusterArns {
+		scaleDown := alert.NewAlert(alert.ScaleDown, alert.Resources, clusterArn, "")
+		scaleDown.Transition(alert.Pending, "raised")
+		if err := store.Save(clusterArn, []*alert.Alert{scaleDown}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != len(clusterArns) {
+		t.Fatalf("loaded %d clusters, want %d", len(clusters), len(clusterArns))
+	}
+	for _, clusterArn := range clusterArns {
+		alerts := clusters[clusterArn]
+		if len(alerts) != 1 || alerts[0].Type != alert.ScaleDown || alerts[0].Status != alert.Pending || alerts[0].ClusterArn != clusterArn {
+			t.Fatalf("loaded %v for %s, want its Pending ScaleDown alert", alerts, clusterArn)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+// FileStore keeps cluster alerts in a local JSON file
+type FileStore struct {
+	fileName string
+	clusters map[string][]*alert.Alert
+	mutex    sync.Mutex
+}
+
+// NewFileStore returns a FileStore backed by the given file, which is
+// created on the first Save
+func NewFileStore(fileName string) *FileStore {
+	return &FileStore{
+		fileName: fileName,
+		clusters: make(map[string][]*alert.Alert),
+	}
+}
+
+func (s *FileStore) Load() (map[string][]*alert.Alert, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := ioutil.ReadFile(s.fileName)
+	if os.IsNotExist(err) {
+		return make(map[string][]*alert.Alert), nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+
+	clusters := make(map[string][]*alert.Alert)
+	err = json.Unmarshal(file, &clusters)
+	if err != nil {
+		return nil, errors.Wrap(err, 1)
+	}
+	s.clusters = clusters
+
+	response := make(map[string][]*alert.Alert)
+	for clusterArn, alerts := range clusters {
+		response[clusterArn] = alerts
+	}
+	return response, nil
+}
+
+// Save writes the whole file through a temporary file so a crash mid-write
+// never leaves a truncated state behind
+func (s *FileStore) Save(clusterArn string, alerts []*alert.Alert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clusters[clusterArn] = alerts
+	file, err := json.MarshalIndent(s.clusters, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.fileName), filepath.Base(s.fileName)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, 1)
+	}
+	_, err = tmp.Write(file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, 1)
+	}
+
+	err = os.Rename(tmp.Name(), s.fileName)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, 1)
+	}
+	return nil
+}
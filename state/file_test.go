@@ -0,0 +1,101 @@
+package state
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+func TestFileStoreLoadsNothingBeforeFirstSave(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	clusters, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 0 {
+		t.Fatalf("loaded %v, want nothing", clusters)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	web := "arn:aws:ecs:us-west-2:123456789012:cluster/web"
+	api := "arn:aws:ecs:us-west-2:123456789012:cluster/api"
+
+	retire := alert.NewAlert(alert.Retire, alert.Image, web, "instance/1")
+	retire.Transition(alert.Pending, "raised")
+	retire.Transition(alert.InProgress, "replacements launched")
+	retire.Step = alert.Drain
+	retire.ContainerInstanceArns = []string{"instance/1", "instance/2"}
+	scaleUp := alert.NewAlert(alert.ScaleUp, alert.Resources, api, "")
+
+	store := NewFileStore(fileName)
+	if err := store.Save(web, []*alert.Alert{retire}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(api, []*alert.Alert{scaleUp}); err != nil {
+		t.Fatal(err)
+	}
+
+	//a new store, as after a restart, reads both clusters back
+	clusters, err := NewFileStore(fileName).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || len(clusters[web]) != 1 || len(clusters[api]) != 1 {
+		t.Fatalf("loaded %v, want one alert for each of two clusters", clusters)
+	}
+	loaded := clusters[web][0]
+	if loaded.Type != alert.Retire || loaded.Status != alert.InProgress || loaded.Step != alert.Drain {
+		t.Fatalf("loaded %v at step %s, want an InProgress Retire alert at step Drain", loaded, loaded.Step)
+	}
+	if !reflect.DeepEqual(loaded.ContainerInstanceArns, retire.ContainerInstanceArns) {
+		t.Fatalf("loaded instances %v, want %v", loaded.ContainerInstanceArns, retire.ContainerInstanceArns)
+	}
+	if len(loaded.History) != len(retire.History) {
+		t.Fatalf("loaded %d history entries, want %d", len(loaded.History), len(retire.History))
+	}
+}
+
+func TestFileStoreSaveReplacesCluster(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	web := "arn:aws:ecs:us-west-2:123456789012:cluster/web"
+
+	store := NewFileStore(fileName)
+	if err := store.Save(web, []*alert.Alert{alert.NewAlert(alert.ScaleUp, alert.Resources, web, "")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(web, []*alert.Alert{}); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := NewFileStore(fileName).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alerts, ok := clusters[web]; !ok || len(alerts) != 0 {
+		t.Fatalf("loaded %v, want no alerts for %s", clusters, web)
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("found %d files, want only the state file", len(entries))
+	}
+}
+
+func TestFileStoreRejectsCorruptFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	if err := ioutil.WriteFile(fileName, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileStore(fileName).Load(); err == nil {
+		t.Fatal("loading a corrupt file succeeded")
+	}
+}
@@ -0,0 +1,14 @@
+package state
+
+import (
+	"github.com/sd-charris/ecs-manager/alert"
+)
+
+// Store persists the alerts of each cluster so that in-flight operations can
+// resume after the manager restarts
+type Store interface {
+	// Load returns the saved alerts keyed by cluster arn
+	Load() (map[string][]*alert.Alert, error)
+	// Save replaces the saved alerts of a cluster
+	Save(clusterArn string, alerts []*alert.Alert) error
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sd-charris/ecs-manager/action"
+	"github.com/sd-charris/ecs-manager/alert"
+	"github.com/sd-charris/ecs-manager/circuit"
+	"github.com/sd-charris/ecs-manager/ecs"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultActionsLimit = 50
+
+type alertStatus struct {
+	Type                 string
+	Status               string
+	Trigger              string
+	EventCount           int64
+	ContainerInstanceArn string
+	AlertDate            time.Time
+	LastActionDate       time.Time
+	History              []alert.HistoryEntry
+}
+
+type checkStatus struct {
+	Name     string
+	Alerts   int
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+type clusterStatus struct {
+	ClusterArn           string
+	AccountId            string
+	Region               string
+	TotalCPU             int64
+	TotalRemainingCPU    int64
+	TotalMemory          int64
+	TotalRemainingMemory int64
+	TotalRunningTasks    *int64
+	TotalPendingTasks    *int64
+	ServiceCount         int
+	TargetImageId        string `json:",omitempty"`
+	OutdatedInstances    int
+	AutoScalingGroup     *ecs.AutoScalingGroupDetails
+	ContainerInstances   []*ecs.ContainerInstance
+	Alerts               []*alertStatus
+	Checks               []*checkStatus
+	Circuit              circuit.State
+	ConsecutiveFailures  int
+	LastError            string `json:",omitempty"`
+}
+
+type managerStatus struct {
+	LastCheck time.Time
+	DryRun    bool
+	Leader    string
+	Identity  string
+	IsLeader  bool
+	Clusters  int
+}
+
+// snapshot is the state published at the end of every check so the HTTP
+// handlers never read the clusters while process() is changing them
+var snapshot struct {
+	sync.RWMutex
+	lastCheck time.Time
+	clusters  []*clusterStatus
+}
+
+func newAlertStatus(alertItem *alert.Alert) *alertStatus {
+	return &alertStatus{
+		Type:                 alertItem.Type.String(),
+		Status:               alertItem.Status.String(),
+		Trigger:              alertItem.Trigger.String(),
+		EventCount:           alertItem.EventCount,
+		ContainerInstanceArn: alertItem.ContainerInstanceArn,
+		AlertDate:            alertItem.AlertDate,
+		LastActionDate:       alertItem.LastActionDate,
+		History:              alertItem.History,
+	}
+}
+
+// publishStatus copies the latest cluster details and alerts for the status API
+func publishStatus() {
+	clusters := make([]*clusterStatus, 0, len(ecsClusters))
+	for clusterArn, ecsCluster := range ecsClusters {
+		status := &clusterStatus{
+			ClusterArn: clusterArn,
+			Alerts:     make([]*alertStatus, 0, len(ecsCluster.Alerts)),
+			Checks:     make([]*checkStatus, 0, len(ecsCluster.CheckResults)),
+		}
+		if details := ecsCluster.ClusterDetails; details != nil {
+			status.AccountId = details.AccountId
+			status.Region = details.Region
+			status.TotalCPU = details.TotalCPU
+			status.TotalRemainingCPU = details.TotalRemainingCPU
+			status.TotalMemory = details.TotalMemory
+			status.TotalRemainingMemory = details.TotalRemainingMemory
+			status.TotalRunningTasks = details.TotalRunningTasks
+			status.TotalPendingTasks = details.TotalPendingTasks
+			status.ServiceCount = len(details.Services)
+			status.AutoScalingGroup = details.AutoScalingGroup
+			status.ContainerInstances = details.ContainerInstances
+			status.TargetImageId = details.TargetImageId
+			if details.TargetImageId != "" {
+				status.OutdatedInstances = len(outdatedInstances(details))
+			}
+		}
+		if ecsCluster.Breaker != nil {
+			status.Circuit = ecsCluster.Breaker.State(time.Now())
+			status.ConsecutiveFailures = ecsCluster.Breaker.Failures()
+		}
+		status.LastError = ecsCluster.LastError
+		for _, alertItem := range ecsCluster.Alerts {
+			status.Alerts = append(status.Alerts, newAlertStatus(alertItem))
+		}
+		for _, result := range ecsCluster.CheckResults {
+			checkItem := &checkStatus{Name: result.Name, Alerts: len(result.Alerts), Duration: result.Duration}
+			if result.Error != nil {
+				checkItem.Error = result.Error.Error()
+			}
+			status.Checks = append(status.Checks, checkItem)
+		}
+		clusters = append(clusters, status)
+	}
+
+	snapshot.Lock()
+	defer snapshot.Unlock()
+	snapshot.lastCheck = time.Now()
+	snapshot.clusters = clusters
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// handleStatus describes the manager itself: last check, dry run and leadership
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	snapshot.RLock()
+	status := managerStatus{
+		LastCheck: snapshot.lastCheck,
+		DryRun:    dryRun,
+		IsLeader:  true,
+		Clusters:  len(snapshot.clusters),
+	}
+	snapshot.RUnlock()
+
+	if elector != nil {
+		status.Leader = elector.Leader()
+		status.Identity = elector.Identity()
+		status.IsLeader = elector.IsLeader()
+	}
+	writeJSON(w, status)
+}
+
+// handleClusters lists every cluster, or only the one named by the arn query parameter
+func handleClusters(w http.ResponseWriter, r *http.Request) {
+	clusterArn := r.URL.Query().Get("arn")
+
+	snapshot.RLock()
+	defer snapshot.RUnlock()
+
+	if clusterArn == "" {
+		writeJSON(w, snapshot.clusters)
+		return
+	}
+	for _, cluster := range snapshot.clusters {
+		if cluster.ClusterArn == clusterArn {
+			writeJSON(w, cluster)
+			return
+		}
+	}
+	http.Error(w, "cluster not found", http.StatusNotFound)
+}
+
+// handleAlerts lists the alerts of each cluster, keyed by cluster arn
+func handleAlerts(w http.ResponseWriter, r *http.Request) {
+	clusterArn := r.URL.Query().Get("cluster")
+
+	snapshot.RLock()
+	defer snapshot.RUnlock()
+
+	alerts := make(map[string][]*alertStatus)
+	for _, cluster := range snapshot.clusters {
+		if clusterArn == "" || cluster.ClusterArn == clusterArn {
+			alerts[cluster.ClusterArn] = cluster.Alerts
+		}
+	}
+	writeJSON(w, alerts)
+}
+
+// handleActions lists the most recent actions, newest first, limited by the limit query parameter
+func handleActions(w http.ResponseWriter, r *http.Request) {
+	limit := defaultActionsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	writeJSON(w, action.Recent(limit))
+}
+
+// registerStatusHandlers adds the read-only status API to mux
+func registerStatusHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/clusters", handleClusters)
+	mux.HandleFunc("/alerts", handleAlerts)
+	mux.HandleFunc("/actions", handleActions)
+}